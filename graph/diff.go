@@ -0,0 +1,199 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/avvero/camel-graph/model"
+)
+
+// Diff is the difference between routes of two environments, routes are matched by context and route id
+type Diff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// routes that are only in to environment
+	Added []*RouteRef `json:"added"`
+	// routes that are only in from environment
+	Removed []*RouteRef  `json:"removed"`
+	Changed []*RouteDiff `json:"changed"`
+}
+
+type RouteRef struct {
+	Service string `json:"service"`
+	Context string `json:"context"`
+	Route   string `json:"route"`
+}
+
+// RouteDiff is a route of both environments that differs in state, endpoints or schema
+type RouteDiff struct {
+	RouteRef
+	ToService      string          `json:"toService,omitempty"`
+	FromState      string          `json:"fromState,omitempty"`
+	ToState        string          `json:"toState,omitempty"`
+	AddedInputs    []string        `json:"addedInputs,omitempty"`
+	RemovedInputs  []string        `json:"removedInputs,omitempty"`
+	AddedOutputs   []string        `json:"addedOutputs,omitempty"`
+	RemovedOutputs []string        `json:"removedOutputs,omitempty"`
+	Schema         []*SchemaChange `json:"schema,omitempty"`
+}
+
+type diffRoute struct {
+	service *model.Service
+	route   *model.Route
+}
+
+// Compares routes of environments, result is sorted by context and route id
+func Compare(from *model.Environment, to *model.Environment) *Diff {
+	diff := &Diff{
+		From:    from.Name,
+		To:      to.Name,
+		Added:   make([]*RouteRef, 0),
+		Removed: make([]*RouteRef, 0),
+		Changed: make([]*RouteDiff, 0)}
+	fromRoutes := indexRoutes(from)
+	toRoutes := indexRoutes(to)
+	for _, key := range sortedKeys(fromRoutes, toRoutes) {
+		a, inFrom := fromRoutes[key]
+		b, inTo := toRoutes[key]
+		switch {
+		case !inTo:
+			diff.Removed = append(diff.Removed, newRouteRef(a))
+		case !inFrom:
+			diff.Added = append(diff.Added, newRouteRef(b))
+		default:
+			if changed := compareRoutes(a, b); changed != nil {
+				diff.Changed = append(diff.Changed, changed)
+			}
+		}
+	}
+	return diff
+}
+
+// Returns true if environments have the same routes
+func (diff *Diff) Empty() bool {
+	return len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0
+}
+
+func compareRoutes(a *diffRoute, b *diffRoute) *RouteDiff {
+	changed := &RouteDiff{RouteRef: *newRouteRef(a)}
+	different := false
+	if a.service.Name != b.service.Name {
+		changed.ToService = b.service.Name
+		different = true
+	}
+	if a.route.State != b.route.State {
+		changed.FromState = a.route.State
+		changed.ToState = b.route.State
+		different = true
+	}
+	fromEndpoints, toEndpoints := endpointsOf(a.route), endpointsOf(b.route)
+	changed.AddedInputs = subtract(toEndpoints.Inputs, fromEndpoints.Inputs)
+	changed.RemovedInputs = subtract(fromEndpoints.Inputs, toEndpoints.Inputs)
+	changed.AddedOutputs = subtract(toEndpoints.Outputs, fromEndpoints.Outputs)
+	changed.RemovedOutputs = subtract(fromEndpoints.Outputs, toEndpoints.Outputs)
+	changed.Schema = CompareSchemas(a.route.Schema, b.route.Schema)
+	if different || len(changed.AddedInputs) > 0 || len(changed.RemovedInputs) > 0 ||
+		len(changed.AddedOutputs) > 0 || len(changed.RemovedOutputs) > 0 || len(changed.Schema) > 0 {
+		return changed
+	}
+	return nil
+}
+
+// Routes that are removed from service but not evicted yet are not compared
+func indexRoutes(environment *model.Environment) map[string]*diffRoute {
+	routes := make(map[string]*diffRoute)
+	for _, service := range sortedServices(environment) {
+		for _, route := range sortedRoutes(service) {
+			if route.RemovedAt != nil {
+				continue
+			}
+			key := route.Context + "/" + route.Name
+			if _, exists := routes[key]; !exists {
+				routes[key] = &diffRoute{service: service, route: route}
+			}
+		}
+	}
+	return routes
+}
+
+func newRouteRef(r *diffRoute) *RouteRef {
+	return &RouteRef{Service: r.service.Name, Context: r.route.Context, Route: r.route.Name}
+}
+
+func endpointsOf(route *model.Route) *model.Endpoints {
+	if route.Endpoints == nil {
+		return &model.Endpoints{}
+	}
+	return route.Endpoints
+}
+
+func sortedKeys(maps ...map[string]*diffRoute) []string {
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns entries of a that are not in b
+func subtract(a []string, b []string) []string {
+	var result []string
+	for _, entry := range a {
+		found := false
+		for _, other := range b {
+			if entry == other {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// Renders diff as text: - for removed routes, + for added ones, ~ for changed ones with details
+func WriteDiff(w io.Writer, diff *Diff) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", diff.From, diff.To)
+	for _, route := range diff.Removed {
+		fmt.Fprintf(buf, "- %s %s/%s\n", route.Service, route.Context, route.Route)
+	}
+	for _, route := range diff.Added {
+		fmt.Fprintf(buf, "+ %s %s/%s\n", route.Service, route.Context, route.Route)
+	}
+	for _, route := range diff.Changed {
+		fmt.Fprintf(buf, "~ %s %s/%s\n", route.Service, route.Context, route.Route)
+		if route.ToService != "" {
+			fmt.Fprintf(buf, "    service: %s -> %s\n", route.Service, route.ToService)
+		}
+		if route.FromState != route.ToState {
+			fmt.Fprintf(buf, "    state: %s -> %s\n", route.FromState, route.ToState)
+		}
+		writeEndpoints(buf, "input", "-", route.RemovedInputs)
+		writeEndpoints(buf, "input", "+", route.AddedInputs)
+		writeEndpoints(buf, "output", "-", route.RemovedOutputs)
+		writeEndpoints(buf, "output", "+", route.AddedOutputs)
+		for _, change := range route.Schema {
+			fmt.Fprintf(buf, "    schema %s: %q -> %q\n", change.Path, change.From, change.To)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeEndpoints(buf *bytes.Buffer, kind string, sign string, endpoints []string) {
+	for _, endpoint := range endpoints {
+		fmt.Fprintf(buf, "    %s %s%s\n", kind, sign, endpoint)
+	}
+}
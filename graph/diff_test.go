@@ -0,0 +1,36 @@
+package graph
+
+import (
+	"testing"
+	"time"
+
+	"github.com/avvero/camel-graph/model"
+)
+
+func diffTestEnvironment(name string, routes ...*model.Route) *model.Environment {
+	service := &model.Service{Name: "smx", RouteMap: make(map[string]*model.Route)}
+	for _, route := range routes {
+		service.RouteMap[route.Name] = route
+	}
+	return &model.Environment{Name: name, ServiceMap: map[string]*model.Service{"smx": service}}
+}
+
+func TestCompareSkipsRemovedRoutes(t *testing.T) {
+	removedAt := model.JsonTime(time.Now())
+	from := diffTestEnvironment("dev",
+		&model.Route{Context: "camel", Name: "orders", State: "Started"},
+		&model.Route{Context: "camel", Name: "billing", State: "Started"})
+	to := diffTestEnvironment("prod",
+		&model.Route{Context: "camel", Name: "orders", State: "Started"},
+		&model.Route{Context: "camel", Name: "billing", State: "Started", RemovedAt: &removedAt})
+	diff := Compare(from, to)
+	if len(diff.Removed) != 1 || diff.Removed[0].Route != "billing" {
+		t.Errorf("removed = %v, want billing", diff.Removed)
+	}
+	if len(diff.Added) != 0 || len(diff.Changed) != 0 {
+		t.Errorf("unexpected added %v or changed %v", diff.Added, diff.Changed)
+	}
+	if !Compare(to, to).Empty() {
+		t.Error("environment must not differ from itself")
+	}
+}
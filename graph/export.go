@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// cluster is a group of nodes belonging to one service
+type cluster struct {
+	service string
+	color   string
+	nodes   []*Node
+}
+
+// Renders graph as Graphviz DOT, services become clusters
+func WriteDot(w io.Writer, graph *Graph) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "digraph %s {\n", dotQuote(graph.Environment))
+	buf.WriteString("  rankdir=LR;\n")
+	buf.WriteString("  node [shape=box, style=rounded];\n")
+	for i, c := range graph.clusters() {
+		fmt.Fprintf(buf, "  subgraph cluster_%v {\n", i)
+		fmt.Fprintf(buf, "    label=%s;\n", dotQuote(c.service))
+		if c.color != "" {
+			fmt.Fprintf(buf, "    color=%s;\n", dotQuote(c.color))
+		}
+		for _, node := range c.nodes {
+			fmt.Fprintf(buf, "    n%v [label=%s];\n", node.Id, dotQuote(node.Label))
+		}
+		buf.WriteString("  }\n")
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(buf, "  n%v -> n%v [label=%s];\n", edge.From, edge.To, dotQuote(edgeLabel(edge, "\n")))
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Renders graph as Mermaid flowchart, services become subgraphs
+func WriteMermaid(w io.Writer, graph *Graph) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("flowchart LR\n")
+	clusters := graph.clusters()
+	for i, c := range clusters {
+		fmt.Fprintf(buf, "  subgraph s%v [%s]\n", i, mermaidQuote(c.service))
+		for _, node := range c.nodes {
+			fmt.Fprintf(buf, "    n%v[%s]\n", node.Id, mermaidQuote(node.Label))
+		}
+		buf.WriteString("  end\n")
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(buf, "  n%v -->|%s| n%v\n", edge.From, mermaidQuote(edgeLabel(edge, " ")), edge.To)
+	}
+	for i, c := range clusters {
+		if c.color != "" {
+			fmt.Fprintf(buf, "  style s%v stroke:%s\n", i, c.color)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Groups nodes by service keeping order of nodes
+func (graph *Graph) clusters() []*cluster {
+	clusters := make([]*cluster, 0)
+	index := make(map[string]*cluster)
+	for _, node := range graph.Nodes {
+		c, exists := index[node.Service]
+		if !exists {
+			c = &cluster{service: node.Service, color: node.Color, nodes: make([]*Node, 0)}
+			index[node.Service] = c
+			clusters = append(clusters, c)
+		}
+		c.nodes = append(c.nodes, node)
+	}
+	return clusters
+}
+
+func edgeLabel(edge *Edge, separator string) string {
+	return fmt.Sprintf("%s%s(%v)", edge.Route, separator, edge.ExchangesTotal)
+}
+
+func dotQuote(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "\"", "\\\"", -1)
+	s = strings.Replace(s, "\n", "\\n", -1)
+	return "\"" + s + "\""
+}
+
+func mermaidQuote(s string) string {
+	s = strings.Replace(s, "\"", "#quot;", -1)
+	return "\"" + s + "\""
+}
@@ -0,0 +1,183 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/avvero/camel-graph/model"
+)
+
+// Graph is the endpoint-to-endpoint topology of an environment, the same one the UI draws
+type Graph struct {
+	Environment string  `json:"environment,omitempty"`
+	Nodes       []*Node `json:"nodes"`
+	Edges       []*Edge `json:"edges"`
+
+	nodeIndex map[string]*Node
+	edgeIndex map[string]*Edge
+}
+
+// Node is a cleaned endpoint, it belongs to the service that mentioned it first
+type Node struct {
+	Id      int    `json:"id"`
+	Label   string `json:"label"`
+	Service string `json:"service,omitempty"`
+	Color   string `json:"color,omitempty"`
+}
+
+// Edge is a route connecting one of its inputs with one of its outputs
+type Edge struct {
+	Id      string `json:"id"`
+	From    int    `json:"from"`
+	To      int    `json:"to"`
+	Service string `json:"service,omitempty"`
+	Context string `json:"context,omitempty"`
+	Route   string `json:"route,omitempty"`
+	State   string `json:"state,omitempty"`
+	Uptime  string `json:"uptime,omitempty"`
+	// metrics
+	ExchangesTotal      int `json:"exchangesTotal,omitempty"`
+	ExchangesCompleted  int `json:"exchangesCompleted,omitempty"`
+	ExchangesFailed     int `json:"exchangesFailed,omitempty"`
+	ExchangesInflight   int `json:"exchangesInflight,omitempty"`
+	MaxProcessingTime   int `json:"maxProcessingTime,omitempty"`
+	MinProcessingTime   int `json:"minProcessingTime,omitempty"`
+	LastProcessingTime  int `json:"lastProcessingTime,omitempty"`
+	MeanProcessingTime  int `json:"meanProcessingTime,omitempty"`
+	TotalProcessingTime int `json:"totalProcessingTime,omitempty"`
+	FailuresHandled     int `json:"failuresHandled,omitempty"`
+	Redeliveries        int `json:"redeliveries,omitempty"`
+	// changes since previous poll
+	ExchangesPerSecond float64 `json:"exchangesPerSecond,omitempty"`
+	FailuresPerSecond  float64 `json:"failuresPerSecond,omitempty"`
+	FailureRatio       float64 `json:"failureRatio,omitempty"`
+}
+
+// Builds graph from environment, services and routes are walked in name order so ids are stable
+func Build(environment *model.Environment) *Graph {
+	graph := &Graph{
+		Environment: environment.Name,
+		Nodes:       make([]*Node, 0),
+		Edges:       make([]*Edge, 0),
+		nodeIndex:   make(map[string]*Node),
+		edgeIndex:   make(map[string]*Edge)}
+
+	services := sortedServices(environment)
+	// Created elements from endpoints
+	for _, service := range services {
+		for _, route := range sortedRoutes(service) {
+			if route.Endpoints == nil {
+				continue
+			}
+			for _, endpoint := range route.Endpoints.Inputs {
+				graph.addNode(endpoint, service)
+			}
+			for _, endpoint := range route.Endpoints.Outputs {
+				if isPlaceholder(endpoint) {
+					continue
+				}
+				graph.addNode(endpoint, service)
+			}
+		}
+	}
+	// Create edges
+	for _, service := range services {
+		for _, route := range sortedRoutes(service) {
+			if route.Endpoints == nil {
+				continue
+			}
+			for _, input := range route.Endpoints.Inputs {
+				for _, output := range route.Endpoints.Outputs {
+					from, fromExists := graph.nodeIndex[input]
+					to, toExists := graph.nodeIndex[output]
+					if fromExists && toExists {
+						graph.addEdge(route, from, to, service)
+					}
+				}
+			}
+		}
+	}
+	return graph
+}
+
+// Returns node by endpoint
+func (graph *Graph) Node(endpoint string) *Node {
+	return graph.nodeIndex[endpoint]
+}
+
+func (graph *Graph) addNode(endpoint string, service *model.Service) {
+	if _, exists := graph.nodeIndex[endpoint]; exists {
+		return
+	}
+	node := &Node{
+		Id:      len(graph.Nodes),
+		Label:   endpoint,
+		Service: service.Name,
+		Color:   service.Color}
+	graph.Nodes = append(graph.Nodes, node)
+	graph.nodeIndex[endpoint] = node
+}
+
+func (graph *Graph) addEdge(route *model.Route, from *Node, to *Node, service *model.Service) {
+	id := fmt.Sprintf("%v_%v", from.Id, to.Id)
+	if _, exists := graph.edgeIndex[id]; exists {
+		return
+	}
+	edge := &Edge{
+		Id:                  id,
+		From:                from.Id,
+		To:                  to.Id,
+		Service:             service.Name,
+		Context:             route.Context,
+		Route:               route.Name,
+		State:               route.State,
+		Uptime:              route.Uptime,
+		ExchangesTotal:      route.ExchangesTotal,
+		ExchangesCompleted:  route.ExchangesCompleted,
+		ExchangesFailed:     route.ExchangesFailed,
+		ExchangesInflight:   route.ExchangesInflight,
+		MaxProcessingTime:   route.MaxProcessingTime,
+		MinProcessingTime:   route.MinProcessingTime,
+		LastProcessingTime:  route.LastProcessingTime,
+		MeanProcessingTime:  route.MeanProcessingTime,
+		TotalProcessingTime: route.TotalProcessingTime,
+		FailuresHandled:     route.FailuresHandled,
+		Redeliveries:        route.Redeliveries,
+		ExchangesPerSecond:  route.ExchangesPerSecond,
+		FailuresPerSecond:   route.FailuresPerSecond,
+		FailureRatio:        route.FailureRatio}
+	graph.Edges = append(graph.Edges, edge)
+	graph.edgeIndex[id] = edge
+}
+
+// skip configured and not filled endpoints
+func isPlaceholder(endpoint string) bool {
+	return strings.Contains(endpoint, "{{")
+}
+
+func sortedServices(environment *model.Environment) []*model.Service {
+	names := make([]string, 0, len(environment.ServiceMap))
+	for name := range environment.ServiceMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	services := make([]*model.Service, len(names))
+	for i, name := range names {
+		services[i] = environment.ServiceMap[name]
+	}
+	return services
+}
+
+func sortedRoutes(service *model.Service) []*model.Route {
+	names := make([]string, 0, len(service.RouteMap))
+	for name := range service.RouteMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	routes := make([]*model.Route, len(names))
+	for i, name := range names {
+		routes[i] = service.RouteMap[name]
+	}
+	return routes
+}
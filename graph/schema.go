@@ -0,0 +1,201 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/antchfx/xquery/xml"
+)
+
+// SchemaChange is a difference of route schemas: changed attribute or text, added or removed element
+type SchemaChange struct {
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// Compares route xml schemas ignoring formatting and attribute order, child elements are aligned
+// by name and uri so inserted processor does not shift the rest. Generated ids (to1, log2, ...) are ignored,
+// they differ between contexts. Schemas that are not xml are compared as text.
+func CompareSchemas(a string, b string) []*SchemaChange {
+	if a == b {
+		return nil
+	}
+	fromRoot, fromErr := parseSchema(a)
+	toRoot, toErr := parseSchema(b)
+	if fromErr != nil || toErr != nil || fromRoot == nil || toRoot == nil {
+		return []*SchemaChange{{Path: "/", From: a, To: b}}
+	}
+	changes := make([]*SchemaChange, 0)
+	if fromRoot.Data != toRoot.Data {
+		return append(changes, &SchemaChange{Path: "/", From: startTag(fromRoot), To: startTag(toRoot)})
+	}
+	compareElements("/"+fromRoot.Data, fromRoot, toRoot, &changes)
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func parseSchema(schema string) (*xmlquery.Node, error) {
+	if schema == "" {
+		return nil, nil
+	}
+	document, err := xmlquery.Parse(strings.NewReader(schema))
+	if err != nil || document == nil {
+		return nil, err
+	}
+	for child := document.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.ElementNode {
+			return child, nil
+		}
+	}
+	return nil, nil
+}
+
+func compareElements(path string, a *xmlquery.Node, b *xmlquery.Node, changes *[]*SchemaChange) {
+	fromAttributes, toAttributes := attributes(a), attributes(b)
+	for _, name := range sortedNames(fromAttributes, toAttributes) {
+		if fromAttributes[name] != toAttributes[name] {
+			*changes = append(*changes, &SchemaChange{
+				Path: path + "/@" + name,
+				From: fromAttributes[name],
+				To:   toAttributes[name]})
+		}
+	}
+	if fromText, toText := text(a), text(b); fromText != toText {
+		*changes = append(*changes, &SchemaChange{Path: path + "/text()", From: fromText, To: toText})
+	}
+	fromChildren, toChildren := elements(a), elements(b)
+	fromPaths, toPaths := childPaths(path, fromChildren), childPaths(path, toChildren)
+	i, j := 0, 0
+	for _, match := range alignElements(fromChildren, toChildren) {
+		for ; i < match[0]; i++ {
+			*changes = append(*changes, &SchemaChange{Path: fromPaths[i], From: startTag(fromChildren[i])})
+		}
+		for ; j < match[1]; j++ {
+			*changes = append(*changes, &SchemaChange{Path: toPaths[j], To: startTag(toChildren[j])})
+		}
+		if i < len(fromChildren) && j < len(toChildren) {
+			compareElements(fromPaths[i], fromChildren[i], toChildren[j], changes)
+		}
+		i, j = i+1, j+1
+	}
+}
+
+// Returns pairs of indexes of matched children by longest common subsequence of signatures,
+// the last pair points past the ends of both lists
+func alignElements(a []*xmlquery.Node, b []*xmlquery.Node) [][2]int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if signature(a[i]) == signature(b[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	matches := make([][2]int, 0)
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if signature(a[i]) == signature(b[j]) {
+			matches = append(matches, [2]int{i, j})
+			i, j = i+1, j+1
+		} else if lengths[i+1][j] >= lengths[i][j+1] {
+			i++
+		} else {
+			j++
+		}
+	}
+	return append(matches, [2]int{len(a), len(b)})
+}
+
+// Elements with the same name and uri are the same processor
+func signature(node *xmlquery.Node) string {
+	attributes := attributes(node)
+	return node.Data + " " + attributes["uri"] + " " + attributes["id"]
+}
+
+// Paths of children like /route/to[2], index is counted among siblings with the same name
+func childPaths(path string, children []*xmlquery.Node) []string {
+	counters := make(map[string]int)
+	paths := make([]string, len(children))
+	for i, child := range children {
+		counters[child.Data]++
+		paths[i] = fmt.Sprintf("%s/%s[%v]", path, child.Data, counters[child.Data])
+	}
+	return paths
+}
+
+func elements(node *xmlquery.Node) []*xmlquery.Node {
+	children := make([]*xmlquery.Node, 0)
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.ElementNode {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// Attributes by name, generated id is left out
+func attributes(node *xmlquery.Node) map[string]string {
+	result := make(map[string]string)
+	customId := false
+	for _, attr := range node.Attr {
+		name := attr.Name.Local
+		if attr.Name.Space != "" {
+			name = attr.Name.Space + ":" + name
+		}
+		if name == "customId" {
+			customId = attr.Value == "true"
+			continue
+		}
+		result[name] = attr.Value
+	}
+	if !customId {
+		delete(result, "id")
+	}
+	return result
+}
+
+// Own text of element with collapsed whitespace
+func text(node *xmlquery.Node) string {
+	parts := make([]string, 0)
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.TextNode {
+			parts = append(parts, strings.Fields(child.Data)...)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func startTag(node *xmlquery.Node) string {
+	attributes := attributes(node)
+	tag := "<" + node.Data
+	for _, name := range sortedNames(attributes) {
+		tag += fmt.Sprintf(" %s=%q", name, attributes[name])
+	}
+	return tag + ">"
+}
+
+func sortedNames(maps ...map[string]string) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, m := range maps {
+		for name := range m {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -2,65 +2,228 @@ package main
 
 import (
 	_ "net/http/pprof"
+	"context"
 	"net/http"
 	"encoding/json"
+	"errors"
+	"io"
 	"fmt"
 	"log"
 	"flag"
+	"net/url"
+	"strconv"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"github.com/avvero/camel-graph/model"
+	"github.com/avvero/camel-graph/graph"
 )
 
 var (
 	httpPort                     = flag.String("httpPort", "8080", "http server port")
+	configFile                   = flag.String("config", "services.json", "config file: json, yaml or toml")
 	serviceUpdateIntervalSeconds = flag.Int("serviceUpdateIntervalSeconds", 60, "update interval for infos")
 	routeUpdateIntervalSeconds   = flag.Int("routeUpdateIntervalSeconds", 60, "update interval for infos")
 	graphiteUrl                  = flag.String("graphiteUrl", "", "host and port to send plaint text metrics to graphite")
 	graphiteRepeatSendOnFail     = flag.Bool("graphiteRepeatSendOnFail", false, "repeat send metrcis to graphite on fail")
+	prometheusEnabled            = flag.Bool("prometheusEnabled", false, "expose metrics for prometheus on /metrics")
+	configWatchIntervalSeconds   = flag.Int("configWatchIntervalSeconds", 10, "interval of config file change checks, 0 disables reload on change")
+	shutdownTimeoutSeconds       = flag.Int("shutdownTimeoutSeconds", 25, "time to finish requests, stop polling and flush metrics on SIGTERM")
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		os.Exit(diff(os.Args[2:]))
+	}
 	flag.Parse()
 
-	config, err := model.ReadConfig("services.json")
-	config.ServiceUpdateIntervalSeconds = *serviceUpdateIntervalSeconds
-	config.RouteUpdateIntervalSeconds = *routeUpdateIntervalSeconds
-	if err != nil {
-		panic(fmt.Sprintf("Error during configuration %v", err))
+	if *serviceUpdateIntervalSeconds <= 0 || *routeUpdateIntervalSeconds <= 0 {
+		log.Println("Update intervals must be positive")
+		os.Exit(1)
 	}
+	config := readValidConfig(*configFile)
+	if config == nil {
+		os.Exit(1)
+	}
+	applyIntervalFlags(config)
 
-	var metricConsumer model.MetricConsumer
+	var err error
+	var access *model.Access
+	if config.Access != nil {
+		access, err = model.NewAccess(config.Access)
+		if err != nil {
+			panic(fmt.Sprintf("Error during configuration of access %v", err))
+		}
+		log.Println("Web UI and API require login")
+	}
+
+	// flags add sinks unless the same ones are configured
 	if *graphiteUrl != "" {
-		metricConsumer = model.NewGraphite(*graphiteUrl, *graphiteRepeatSendOnFail)
-		log.Println("Metrics will be passed to graphite: " + *graphiteUrl)
+		if sink := findMetricSink(config, model.METRIC_SINK_GRAPHITE, *graphiteUrl); sink != nil {
+			sink.RepeatSendOnFail = sink.RepeatSendOnFail || *graphiteRepeatSendOnFail
+		} else {
+			config.Metrics = append(config.Metrics, &model.MetricSinkConfig{
+				Type:             model.METRIC_SINK_GRAPHITE,
+				Url:              *graphiteUrl,
+				RepeatSendOnFail: *graphiteRepeatSendOnFail})
+		}
+	}
+	if *prometheusEnabled && findMetricSink(config, model.METRIC_SINK_PROMETHEUS, "") == nil {
+		config.Metrics = append(config.Metrics, &model.MetricSinkConfig{Type: model.METRIC_SINK_PROMETHEUS})
+	}
+	fanOut := model.NewFanOutMetricConsumer()
+	for _, sinkConfig := range config.Metrics {
+		sink, err := model.NewMetricSink(sinkConfig)
+		if err != nil {
+			panic(fmt.Sprintf("Error during configuration of %s metrics %v", sinkConfig.Type, err))
+		}
+		if handler, ok := sink.(http.Handler); ok {
+			path := sinkConfig.Path
+			if path == "" {
+				path = model.DefaultPrometheusPath
+			}
+			http.Handle(path, handler)
+			log.Printf("Metrics will be exposed for %s on %s", sinkConfig.Type, path)
+		} else {
+			log.Printf("Metrics will be passed to %s: %s%s", sinkConfig.Type, sinkConfig.Url, sinkConfig.File)
+		}
+		fanOut.Add(sinkConfig.Type, sink, sinkConfig.BufferSize)
+	}
+	var metricConsumer model.MetricConsumer
+	if fanOut.Len() > 0 {
+		metricConsumer = fanOut
 	} else {
 		metricConsumer = &model.MetricConsumerStub{}
 	}
 
-	instance, err := model.NewInstance(config, &metricConsumer)
+	var history *model.History
+	if config.History != nil {
+		history, err = model.NewHistory(config.History)
+		if err != nil {
+			panic(fmt.Sprintf("Error during opening history %v", err))
+		}
+		http.HandleFunc("/history", historyHandler(history))
+		log.Println("Route history will be kept on disk and exposed on /history")
+	}
+
+	var notifications *model.Notifications
+	if config.Notifications != nil {
+		notifications, err = model.NewNotifications(config.Notifications)
+		if err != nil {
+			panic(fmt.Sprintf("Error during configuration of notifications %v", err))
+		}
+		log.Printf("Notifications will be sent to %v channels", len(config.Notifications.Channels))
+	}
+
+	var topology *model.Topology
+	if config.Topology != nil {
+		topology, err = model.NewTopology(config.Topology)
+		if err != nil {
+			panic(fmt.Sprintf("Error during opening topology %v", err))
+		}
+		http.HandleFunc("/topology/events", topologyEventsHandler(topology))
+		http.HandleFunc("/topology/snapshot", topologySnapshotHandler(topology))
+		log.Println("Topology changes will be kept on disk and exposed on /topology/events and /topology/snapshot")
+	}
+
+	liveUpdates := model.NewLiveUpdates()
+	instance, err := model.NewInstance(config, &metricConsumer, history, notifications, topology, liveUpdates)
 	if err != nil {
 		panic(fmt.Sprintf("Error during configuration %v", err))
 	}
 
+	var alerting *model.Alerting
+	if config.Alerts != nil {
+		alerting, err = model.NewAlerting(config.Alerts)
+		if err != nil {
+			panic(fmt.Sprintf("Error during configuration of alerts %v", err))
+		}
+		if notifications != nil {
+			alerting.AddNotifier(notifications)
+		}
+		alerting.Start(instance)
+		http.HandleFunc("/alerts", func(w http.ResponseWriter, r *http.Request) {
+			principal := model.PrincipalFromRequest(r)
+			alerts := make([]*model.Alert, 0)
+			for _, alert := range alerting.Alerts() {
+				if principal.CanSee(alert.Environment) {
+					alerts = append(alerts, alert)
+				}
+			}
+			js, err := json.Marshal(alerts)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(js)
+		})
+		log.Printf("Alerting is started with %v rules", len(config.Alerts.Rules))
+	}
+
+	// reload
+	reload := func(reason string) error {
+		log.Printf("Configuration is reloading on %s", reason)
+		// the validated config is applied, file is not read again as it could be changed meanwhile
+		newConfig := readValidConfig(*configFile)
+		if newConfig == nil {
+			return errors.New("configuration is invalid")
+		}
+		applyIntervalFlags(newConfig)
+		if err := instance.Reload(newConfig); err != nil {
+			log.Printf("error: could not reload configuration: %s", err)
+			return err
+		}
+		log.Println("Configuration is reloaded")
+		return nil
+	}
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			reload("SIGHUP")
+		}
+	}()
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	if *configWatchIntervalSeconds > 0 {
+		go model.WatchConfig(watchCtx, *configFile, time.Duration(*configWatchIntervalSeconds)*time.Second, func() {
+			reload("file change")
+		})
+	}
+	http.HandleFunc("/admin/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method is not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := reload("request"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
 	// proxy stuff
 	http.Handle("/", http.FileServer(http.Dir("public")))
 	http.HandleFunc("/data", func(w http.ResponseWriter, r *http.Request) {
 		var js []byte
 		var err error
 		envName := r.URL.Query().Get("env")
+		principal := model.PrincipalFromRequest(r)
 		var environmentToReturn *model.Environment
-		if envName != "" {
-			for _, environment := range instance.Environments {
-				if environment.Name == envName {
-					environmentToReturn = environment
-					break
-				}
-			}
+		if envName != "" && principal.CanSee(envName) {
+			environmentToReturn = instance.GetEnvironment(envName)
 		}
 		// marshal
 		if envName != "" && environmentToReturn != nil {
 			js, err = json.Marshal(environmentToReturn)
 		} else {
-			js, err = json.Marshal(instance)
+			js, err = json.Marshal(struct {
+				Environments []*model.Environment `json:"environments,omitempty"`
+			}{instance.GetVisibleEnvironments(principal)})
 		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -69,7 +232,401 @@ func main() {
 		w.Header().Set("Content-Type", "application/json")
 		w.Write(js)
 	})
+	http.HandleFunc("/graph", func(w http.ResponseWriter, r *http.Request) {
+		envName := r.URL.Query().Get("env")
+		environment := instance.GetEnvironment(envName)
+		if environment == nil || !model.PrincipalFromRequest(r).CanSee(envName) {
+			http.Error(w, fmt.Sprintf("Environment %q is not found", envName), http.StatusNotFound)
+			return
+		}
+		js, err := json.Marshal(graph.Build(environment))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+	})
+	http.HandleFunc("/diff", func(w http.ResponseWriter, r *http.Request) {
+		principal := model.PrincipalFromRequest(r)
+		environments := make([]*model.Environment, 2)
+		for i, envName := range []string{r.URL.Query().Get("from"), r.URL.Query().Get("to")} {
+			environments[i] = instance.GetEnvironment(envName)
+			if environments[i] == nil || !principal.CanSee(envName) {
+				http.Error(w, fmt.Sprintf("Environment %q is not found", envName), http.StatusNotFound)
+				return
+			}
+		}
+		js, err := json.Marshal(graph.Compare(environments[0], environments[1]))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+	})
+	http.HandleFunc("/events", liveUpdatesHandler(instance, liveUpdates))
+	http.HandleFunc("/export/dot", exportHandler(instance, graph.WriteDot))
+	http.HandleFunc("/export/mermaid", exportHandler(instance, graph.WriteMermaid))
+
+	var handler http.Handler = http.DefaultServeMux
+	if access != nil {
+		handler = access.Protect(handler, "/admin/", "/debug/pprof/")
+	}
+	server := &http.Server{Addr: ":" + *httpPort, Handler: handler}
+	// event streams do not end by themselves
+	server.RegisterOnShutdown(liveUpdates.Close)
+	go func() {
+		log.Println("Http server started on port " + *httpPort)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("error: http server is failed: %s", err)
+			os.Exit(1)
+		}
+	}()
+
+	terminations := make(chan os.Signal, 1)
+	signal.Notify(terminations, syscall.SIGTERM, os.Interrupt)
+	sig := <-terminations
+	log.Printf("Shutting down on %s within %v seconds", sig, *shutdownTimeoutSeconds)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*shutdownTimeoutSeconds)*time.Second)
+	defer cancel()
+	stopWatch()
+	graceful := true
+	stopped := func(component string, err error) {
+		if err != nil {
+			log.Printf("error: %s is not stopped gracefully: %s", component, err)
+			graceful = false
+		}
+	}
+	// requests in flight are finished first, then pollers stop and pass collected metrics to sinks
+	stopped("http server", server.Shutdown(ctx))
+	stopped("polling", instance.Shutdown(ctx))
+	if alerting != nil {
+		alerting.Stop()
+	}
+	stopped("metrics", fanOut.Close(ctx))
+	if notifications != nil {
+		stopped("notifications", notifications.Close(ctx))
+	}
+	if history != nil {
+		stopped("history", history.Close())
+	}
+	if topology != nil {
+		stopped("topology", topology.Close())
+	}
+	if !graceful {
+		os.Exit(1)
+	}
+	log.Println("Stopped")
+}
+
+// Returns configured sink of type, url is compared if it is not empty
+func findMetricSink(config *model.InstanceConfig, sinkType string, url string) *model.MetricSinkConfig {
+	for _, sink := range config.Metrics {
+		if sink.Type == sinkType && (url == "" || sink.Url == url) {
+			return sink
+		}
+	}
+	return nil
+}
+
+// Handler that renders graph of requested environment as text
+func exportHandler(instance *model.Instance, write func(io.Writer, *graph.Graph) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		envName := r.URL.Query().Get("env")
+		environment := instance.GetEnvironment(envName)
+		if environment == nil || !model.PrincipalFromRequest(r).CanSee(envName) {
+			http.Error(w, fmt.Sprintf("Environment %q is not found", envName), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := write(w, graph.Build(environment)); err != nil {
+			log.Printf("error: could not export %s graph: %s", envName, err)
+		}
+	}
+}
+
+// Handler that streams changes of environment as server-sent events until client disconnects
+func liveUpdatesHandler(instance *model.Instance, liveUpdates *model.LiveUpdates) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		envName := r.URL.Query().Get("env")
+		if instance.GetEnvironment(envName) == nil || !model.PrincipalFromRequest(r).CanSee(envName) {
+			http.Error(w, fmt.Sprintf("Environment %q is not found", envName), http.StatusNotFound)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		updates, unsubscribe := liveUpdates.Subscribe(envName)
+		defer unsubscribe()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		// comments keep connection open through proxies
+		heartbeat := time.NewTicker(model.LiveUpdatesHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			case update, ok := <-updates:
+				if !ok {
+					// client is too slow or server is stopping, it reconnects and reads full state
+					return
+				}
+				js, err := json.Marshal(update)
+				if err != nil {
+					log.Printf("error: could not marshal live update of %s: %s", envName, err)
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", update.Type, js)
+			}
+			flusher.Flush()
+		}
+	}
+}
 
-	log.Println("Http server started on port " + *httpPort)
-	http.ListenAndServe(":" + *httpPort, nil)
+// Handler that returns stored counters of a route, time range is the last hour by default
+func historyHandler(history *model.History) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("env") == "" || query.Get("service") == "" || query.Get("route") == "" {
+			http.Error(w, "env, service and route are required", http.StatusBadRequest)
+			return
+		}
+		if !model.PrincipalFromRequest(r).CanSee(query.Get("env")) {
+			http.Error(w, fmt.Sprintf("Environment %q is not found", query.Get("env")), http.StatusNotFound)
+			return
+		}
+		to, err := parseTimeParam(query.Get("to"), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		from, err := parseTimeParam(query.Get("from"), to.Add(-time.Hour))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		series, err := history.Query(query.Get("env"), query.Get("service"), query.Get("context"),
+			query.Get("route"), from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		js, err := json.Marshal(series)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+	}
+}
+
+// Handler that returns topology changes of environment, time range is the last day by default
+func topologyEventsHandler(topology *model.Topology) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("env") == "" || !model.PrincipalFromRequest(r).CanSee(query.Get("env")) {
+			http.Error(w, fmt.Sprintf("Environment %q is not found", query.Get("env")), http.StatusNotFound)
+			return
+		}
+		to, err := parseTimeParam(query.Get("to"), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		from, err := parseTimeParam(query.Get("from"), to.Add(-24*time.Hour))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		events, err := topology.Events(query.Get("env"), from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		js, err := json.Marshal(events)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+	}
+}
+
+// Handler that returns topology of environment at given time, now by default
+func topologySnapshotHandler(topology *model.Topology) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("env") == "" || !model.PrincipalFromRequest(r).CanSee(query.Get("env")) {
+			http.Error(w, fmt.Sprintf("Environment %q is not found", query.Get("env")), http.StatusNotFound)
+			return
+		}
+		at, err := parseTimeParam(query.Get("at"), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		snapshot, err := topology.Snapshot(query.Get("env"), at)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if snapshot == nil {
+			http.Error(w, fmt.Sprintf("There is no snapshot of %q at %s", query.Get("env"), at.Format(time.RFC3339)),
+				http.StatusNotFound)
+			return
+		}
+		js, err := json.Marshal(snapshot)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+	}
+}
+
+// Parses RFC3339 time or unix seconds
+func parseTimeParam(value string, defaultValue time.Time) (time.Time, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("time %q must be RFC3339 or unix seconds", value)
+	}
+	return t, nil
+}
+
+// Intervals of config are used unless flags are set explicitly
+func applyIntervalFlags(config *model.InstanceConfig) {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	if config.ServiceUpdateIntervalSeconds == 0 || explicit["serviceUpdateIntervalSeconds"] {
+		config.ServiceUpdateIntervalSeconds = *serviceUpdateIntervalSeconds
+	}
+	if config.RouteUpdateIntervalSeconds == 0 || explicit["routeUpdateIntervalSeconds"] {
+		config.RouteUpdateIntervalSeconds = *routeUpdateIntervalSeconds
+	}
+}
+
+// Reads config and logs every problem of it, returns nil if there are any
+func readValidConfig(fileName string) *model.InstanceConfig {
+	config, problems, err := model.ValidateConfig(fileName)
+	if err != nil {
+		log.Printf("error: could not read configuration %s: %s", fileName, err)
+		return nil
+	}
+	for _, problem := range problems {
+		log.Printf("error: configuration %s %s", fileName, problem)
+	}
+	if len(problems) > 0 {
+		return nil
+	}
+	return config
+}
+
+// validate subcommand, returns exit code
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	fileName := flags.String("config", "services.json", "config file: json, yaml or toml")
+	flags.Parse(args)
+
+	_, problems, err := model.ValidateConfig(*fileName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *fileName, err)
+		return 2
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *fileName, problem)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %v problems found\n", *fileName, len(problems))
+		return 1
+	}
+	fmt.Printf("%s: ok\n", *fileName)
+	return 0
+}
+
+// diff subcommand compares environments of running camel-graph, returns exit code like diff does
+func diff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	serverUrl := flags.String("url", "http://localhost:8080", "camel-graph url")
+	from := flags.String("from", "", "environment to compare")
+	to := flags.String("to", "", "environment to compare with")
+	login := flags.String("login", "", "camel-graph login")
+	password := flags.String("password", os.Getenv("CAMEL_GRAPH_PASSWORD"), "camel-graph password")
+	asJson := flags.Bool("json", false, "print diff as json")
+	flags.Parse(args)
+
+	if *from == "" || *to == "" {
+		fmt.Fprintln(os.Stderr, "from and to environments are required")
+		return 2
+	}
+	environments := make([]*model.Environment, 2)
+	for i, envName := range []string{*from, *to} {
+		environment, err := fetchEnvironment(*serverUrl, envName, *login, *password)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", envName, err)
+			return 2
+		}
+		environments[i] = environment
+	}
+	result := graph.Compare(environments[0], environments[1])
+	if *asJson {
+		js, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		fmt.Println(string(js))
+	} else {
+		graph.WriteDiff(os.Stdout, result)
+	}
+	if result.Empty() {
+		return 0
+	}
+	return 1
+}
+
+func fetchEnvironment(serverUrl string, envName string, login string, password string) (*model.Environment, error) {
+	req, err := http.NewRequest("GET", serverUrl+"/data?env="+url.QueryEscape(envName), nil)
+	if err != nil {
+		return nil, err
+	}
+	if login != "" {
+		req.SetBasicAuth(login, password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("Status " + resp.Status)
+	}
+	environment := &model.Environment{}
+	if err = json.NewDecoder(resp.Body).Decode(environment); err != nil {
+		return nil, err
+	}
+	// all environments are returned if requested one is not found
+	if environment.Name != envName {
+		return nil, errors.New("environment is not found")
+	}
+	return environment, nil
 }
@@ -0,0 +1,194 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	AccessRealm          = "camel-graph"
+	PasswordSha256Prefix = "sha256:"
+)
+
+type principalKey struct{}
+
+// Authenticated user of camel-graph with roles that are known to config
+type Principal struct {
+	Login string
+	Roles []string
+	roles []*RoleConfig
+}
+
+// Nil principal means access control is off and everything is allowed
+func (principal *Principal) CanSee(environment string) bool {
+	if principal == nil {
+		return true
+	}
+	for _, role := range principal.roles {
+		if len(role.Environments) == 0 || contains(role.Environments, environment) {
+			return true
+		}
+	}
+	return false
+}
+
+func (principal *Principal) IsAdmin() bool {
+	if principal == nil {
+		return true
+	}
+	for _, role := range principal.roles {
+		if role.Admin {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns principal of request authenticated by Access.Protect, nil if access control is off
+func PrincipalFromRequest(r *http.Request) *Principal {
+	principal, _ := r.Context().Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// Checks users of camel-graph web UI and API
+type Access struct {
+	config *AccessConfig
+	users  map[string]*UserConfig
+	roles  map[string]*RoleConfig
+}
+
+func NewAccess(config *AccessConfig) (*Access, error) {
+	if config.UsersFile == "" && config.ProxyHeader == "" {
+		return nil, errors.New("users file or proxy header is required")
+	}
+	access := &Access{
+		config: config,
+		users:  make(map[string]*UserConfig),
+		roles:  make(map[string]*RoleConfig)}
+	for _, role := range config.Roles {
+		access.roles[role.Name] = role
+	}
+	if config.UsersFile != "" {
+		users, err := ReadUsers(config.UsersFile)
+		if err != nil {
+			return nil, fmt.Errorf("users file %s: %s", config.UsersFile, err)
+		}
+		for _, user := range users.Users {
+			access.users[user.Login] = user
+		}
+	}
+	return access, nil
+}
+
+// Reads users file, it may be json, yaml or toml like config
+func ReadUsers(fileName string) (*UsersConfig, error) {
+	problems := &configProblems{}
+	tree, err := readConfigTree(fileName, problems)
+	if err != nil {
+		return nil, err
+	}
+	if err = problems.err(); err != nil {
+		return nil, err
+	}
+	users := &UsersConfig{}
+	if err = decodeTree(tree, users); err != nil {
+		return nil, err
+	}
+	for i, user := range users.Users {
+		if user.Login == "" {
+			return nil, fmt.Errorf("login of user %v must not be empty", i)
+		}
+	}
+	return users, nil
+}
+
+// Authenticates every request, paths with admin prefixes require admin role
+func (access *Access) Protect(handler http.Handler, adminPrefixes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := access.authenticate(r)
+		if err != nil {
+			if access.config.ProxyHeader == "" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", AccessRealm))
+			}
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		for _, prefix := range adminPrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) && !principal.IsAdmin() {
+				http.Error(w, "Admin role is required", http.StatusForbidden)
+				return
+			}
+		}
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	})
+}
+
+func (access *Access) authenticate(r *http.Request) (*Principal, error) {
+	if access.config.ProxyHeader != "" {
+		login := r.Header.Get(access.config.ProxyHeader)
+		if login == "" {
+			return nil, errors.New("User is not authenticated by proxy")
+		}
+		var roles []string
+		if access.config.ProxyRolesHeader != "" && r.Header.Get(access.config.ProxyRolesHeader) != "" {
+			for _, role := range strings.Split(r.Header.Get(access.config.ProxyRolesHeader), ",") {
+				roles = append(roles, strings.TrimSpace(role))
+			}
+		} else if user, exists := access.users[login]; exists {
+			roles = user.Roles
+		} else {
+			roles = access.config.DefaultRoles
+		}
+		return access.newPrincipal(login, roles), nil
+	}
+	login, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, errors.New("Login is required")
+	}
+	user, exists := access.users[login]
+	if !exists || !checkPassword(user.Password, password) {
+		return nil, errors.New("Login or password is wrong")
+	}
+	return access.newPrincipal(login, user.Roles), nil
+}
+
+// Roles unknown to config are ignored
+func (access *Access) newPrincipal(login string, roles []string) *Principal {
+	principal := &Principal{Login: login, Roles: roles}
+	for _, name := range roles {
+		if role, exists := access.roles[name]; exists {
+			principal.roles = append(principal.roles, role)
+		}
+	}
+	return principal
+}
+
+func checkPassword(expected, password string) bool {
+	if strings.HasPrefix(expected, PasswordSha256Prefix) {
+		sum := sha256.Sum256([]byte(password))
+		expected = strings.ToLower(strings.TrimPrefix(expected, PasswordSha256Prefix))
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(expected)) == 1
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1
+}
+
+// Returns environments that principal can see
+func (instance *Instance) GetVisibleEnvironments(principal *Principal) []*Environment {
+	environments := instance.GetEnvironments()
+	if principal == nil {
+		return environments
+	}
+	visible := make([]*Environment, 0, len(environments))
+	for _, environment := range environments {
+		if principal.CanSee(environment.Name) {
+			visible = append(visible, environment)
+		}
+	}
+	return visible
+}
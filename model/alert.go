@@ -0,0 +1,262 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ALERT_ROUTE_STATE        = "route_state"
+	ALERT_ROUTE_FAILURE_RATE = "route_failure_rate"
+	ALERT_ROUTE_INFLIGHT     = "route_inflight"
+	ALERT_SERVICE_FAILED     = "service_failed"
+
+	ALERT_STATUS_PENDING  = "pending"
+	ALERT_STATUS_FIRING   = "firing"
+	ALERT_STATUS_RESOLVED = "resolved"
+
+	STARTED = "Started"
+
+	DefaultAlertEvaluationIntervalSeconds = 15
+)
+
+type Alert struct {
+	Rule        string   `json:"rule"`
+	Severity    string   `json:"severity,omitempty"`
+	Environment string   `json:"environment"`
+	Service     string   `json:"service"`
+	Context     string   `json:"context,omitempty"`
+	Route       string   `json:"route,omitempty"`
+	Status      string   `json:"status"`
+	Message     string   `json:"message"`
+	Value       float64  `json:"value"`
+	StartsAt    JsonTime `json:"startsAt"`
+	FiredAt     JsonTime `json:"firedAt"`
+	ResolvedAt  JsonTime `json:"resolvedAt"`
+}
+
+// Receives alerts when they fire and when they are resolved
+type AlertNotifier interface {
+	NotifyAlert(alert *Alert)
+}
+
+// Logs alerts
+type AlertLogger struct {
+}
+
+func (it *AlertLogger) NotifyAlert(alert *Alert) {
+	log.Printf("alert: [%s] %s %s:%s:%s %s", alert.Status, alert.Rule, alert.Environment, alert.Service, alert.Route,
+		alert.Message)
+}
+
+// Evaluates rules against collected data, keeps every alert once per target until it is resolved
+type Alerting struct {
+	mutex     sync.RWMutex
+	rules     []*AlertRuleConfig
+	interval  time.Duration
+	alerts    map[string]*Alert
+	notifiers []AlertNotifier
+	stop      chan struct{}
+}
+
+// result of rule check on one target
+type alertCondition struct {
+	environment string
+	service     string
+	context     string
+	route       string
+	value       float64
+	message     string
+}
+
+func NewAlerting(config *AlertsConfig) (*Alerting, error) {
+	for _, rule := range config.Rules {
+		if rule.Name == "" {
+			return nil, errors.New("alert rule name must not be empty")
+		}
+		switch rule.Type {
+		case ALERT_ROUTE_STATE, ALERT_ROUTE_FAILURE_RATE, ALERT_ROUTE_INFLIGHT, ALERT_SERVICE_FAILED:
+		default:
+			return nil, fmt.Errorf("alert rule %s has unknown type %q", rule.Name, rule.Type)
+		}
+	}
+	intervalSeconds := config.EvaluationIntervalSeconds
+	if intervalSeconds <= 0 {
+		intervalSeconds = DefaultAlertEvaluationIntervalSeconds
+	}
+	return &Alerting{
+		rules:     config.Rules,
+		interval:  time.Duration(intervalSeconds) * time.Second,
+		alerts:    make(map[string]*Alert),
+		notifiers: []AlertNotifier{&AlertLogger{}},
+		stop:      make(chan struct{})}, nil
+}
+
+// Adds notifier, must be called before start
+func (alerting *Alerting) AddNotifier(notifier AlertNotifier) {
+	alerting.notifiers = append(alerting.notifiers, notifier)
+}
+
+// Starts periodic evaluation of rules
+func (alerting *Alerting) Start(instance *Instance) {
+	go func() {
+		ticker := time.NewTicker(alerting.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-alerting.stop:
+				return
+			case t := <-ticker.C:
+				alerting.evaluate(instance, t)
+			}
+		}
+	}()
+}
+
+// Stops evaluation of rules
+func (alerting *Alerting) Stop() {
+	close(alerting.stop)
+}
+
+// Returns firing alerts
+func (alerting *Alerting) Alerts() []*Alert {
+	alerting.mutex.RLock()
+	defer alerting.mutex.RUnlock()
+	result := make([]*Alert, 0)
+	for _, alert := range alerting.alerts {
+		if alert.Status == ALERT_STATUS_FIRING {
+			copied := *alert
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return time.Time(result[i].FiredAt).Before(time.Time(result[j].FiredAt))
+	})
+	return result
+}
+
+func (alerting *Alerting) evaluate(instance *Instance, t time.Time) {
+	notifications := make([]*Alert, 0)
+	alerting.mutex.Lock()
+	seen := make(map[string]bool)
+	for _, rule := range alerting.rules {
+		for _, condition := range checkRule(rule, instance) {
+			key := fmt.Sprintf("%s/%s/%s/%s/%s", rule.Name, condition.environment, condition.service,
+				condition.context, condition.route)
+			seen[key] = true
+			alert, exists := alerting.alerts[key]
+			if !exists {
+				alert = &Alert{
+					Rule:        rule.Name,
+					Severity:    rule.Severity,
+					Environment: condition.environment,
+					Service:     condition.service,
+					Context:     condition.context,
+					Route:       condition.route,
+					Status:      ALERT_STATUS_PENDING,
+					StartsAt:    JsonTime(t)}
+				alerting.alerts[key] = alert
+			}
+			alert.Value = condition.value
+			alert.Message = condition.message
+			holdFor := time.Duration(rule.ForSeconds) * time.Second
+			if alert.Status == ALERT_STATUS_PENDING && t.Sub(time.Time(alert.StartsAt)) >= holdFor {
+				alert.Status = ALERT_STATUS_FIRING
+				alert.FiredAt = JsonTime(t)
+				copied := *alert
+				notifications = append(notifications, &copied)
+			}
+		}
+	}
+	for key, alert := range alerting.alerts {
+		if seen[key] {
+			continue
+		}
+		delete(alerting.alerts, key)
+		if alert.Status == ALERT_STATUS_FIRING {
+			alert.Status = ALERT_STATUS_RESOLVED
+			alert.ResolvedAt = JsonTime(t)
+			notifications = append(notifications, alert)
+		}
+	}
+	alerting.mutex.Unlock()
+
+	for _, alert := range notifications {
+		for _, notifier := range alerting.notifiers {
+			notifier.NotifyAlert(alert)
+		}
+	}
+}
+
+// Returns targets on which rule condition holds
+func checkRule(rule *AlertRuleConfig, instance *Instance) []*alertCondition {
+	conditions := make([]*alertCondition, 0)
+	for _, environment := range instance.GetEnvironments() {
+		if rule.Environment != "" && rule.Environment != environment.Name {
+			continue
+		}
+		for _, service := range environment.ServiceMap {
+			if rule.Service != "" && rule.Service != service.Name {
+				continue
+			}
+			if rule.Type == ALERT_SERVICE_FAILED {
+				updates := rule.Updates
+				if updates <= 0 {
+					updates = 1
+				}
+				if service.FailedUpdates >= updates {
+					conditions = append(conditions, &alertCondition{
+						environment: environment.Name,
+						service:     service.Name,
+						value:       float64(service.FailedUpdates),
+						message:     fmt.Sprintf("%v updates failed: %s", service.FailedUpdates, service.Error)})
+				}
+				continue
+			}
+			for _, route := range service.RouteMap {
+				if rule.Route != "" && rule.Route != route.Name {
+					continue
+				}
+				if condition := checkRouteRule(rule, route); condition != nil {
+					condition.environment = environment.Name
+					condition.service = service.Name
+					condition.context = route.Context
+					condition.route = route.Name
+					conditions = append(conditions, condition)
+				}
+			}
+		}
+	}
+	return conditions
+}
+
+func checkRouteRule(rule *AlertRuleConfig, route *Route) *alertCondition {
+	switch rule.Type {
+	case ALERT_ROUTE_STATE:
+		expected := rule.State
+		if expected == "" {
+			expected = STARTED
+		}
+		// removed routes are not checked
+		if route.State != expected && route.State != NONE && route.State != "" {
+			return &alertCondition{message: fmt.Sprintf("state is %s, expected %s", route.State, expected)}
+		}
+	case ALERT_ROUTE_FAILURE_RATE:
+		if route.RatesComputed && route.FailuresPerSecond > rule.Threshold {
+			return &alertCondition{
+				value:   route.FailuresPerSecond,
+				message: fmt.Sprintf("%.2f failures per second, limit is %v", route.FailuresPerSecond, rule.Threshold)}
+		}
+	case ALERT_ROUTE_INFLIGHT:
+		if float64(route.ExchangesInflight) > rule.Threshold {
+			return &alertCondition{
+				value:   float64(route.ExchangesInflight),
+				message: fmt.Sprintf("%v exchanges inflight, limit is %v", route.ExchangesInflight, rule.Threshold)}
+		}
+	}
+	return nil
+}
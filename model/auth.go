@@ -0,0 +1,185 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AUTH_BASIC   = "basic"
+	AUTH_BEARER  = "bearer"
+	AUTH_HEADERS = "headers"
+	AUTH_OAUTH2  = "oauth2"
+
+	// token is refreshed a bit before it expires
+	OAuth2ExpiryMargin = 30 * time.Second
+)
+
+// Adds credentials to requests
+type authenticator interface {
+	authenticate(req *http.Request) error
+	// called when credentials are rejected
+	invalidate()
+}
+
+// Creates authenticator described by config, nil config means no authentication.
+// Headers are added for every type, type is basic if it is not set.
+func newAuthenticator(config *Authorization, client *http.Client) (authenticator, error) {
+	if config == nil {
+		return nil, nil
+	}
+	var credentials authenticator
+	switch config.Type {
+	case "", AUTH_BASIC:
+		credentials = &basicAuth{login: config.Login, pass: config.Pass}
+	case AUTH_BEARER:
+		if config.Token == "" {
+			return nil, errors.New("bearer token must not be empty")
+		}
+		credentials = &bearerAuth{token: config.Token}
+	case AUTH_HEADERS:
+		if len(config.Headers) == 0 {
+			return nil, errors.New("headers must not be empty")
+		}
+	case AUTH_OAUTH2:
+		if config.TokenUrl == "" || config.ClientId == "" {
+			return nil, errors.New("oauth2 token url and client id must not be empty")
+		}
+		credentials = &oauth2Auth{
+			client:       client,
+			tokenUrl:     config.TokenUrl,
+			clientId:     config.ClientId,
+			clientSecret: config.ClientSecret,
+			scopes:       config.Scopes}
+	default:
+		return nil, fmt.Errorf("unknown authorization type %q", config.Type)
+	}
+	return &headersAuth{headers: config.Headers, next: credentials}, nil
+}
+
+type headersAuth struct {
+	headers map[string]string
+	next    authenticator
+}
+
+func (auth *headersAuth) authenticate(req *http.Request) error {
+	for name, value := range auth.headers {
+		req.Header.Set(name, value)
+	}
+	if auth.next != nil {
+		return auth.next.authenticate(req)
+	}
+	return nil
+}
+
+func (auth *headersAuth) invalidate() {
+	if auth.next != nil {
+		auth.next.invalidate()
+	}
+}
+
+type basicAuth struct {
+	login string
+	pass  string
+}
+
+func (auth *basicAuth) authenticate(req *http.Request) error {
+	req.SetBasicAuth(auth.login, auth.pass)
+	return nil
+}
+
+func (auth *basicAuth) invalidate() {
+}
+
+type bearerAuth struct {
+	token string
+}
+
+func (auth *bearerAuth) authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+auth.token)
+	return nil
+}
+
+func (auth *bearerAuth) invalidate() {
+}
+
+// OAuth2 client credentials grant, token is cached until it expires or is rejected
+type oauth2Auth struct {
+	client       *http.Client
+	tokenUrl     string
+	clientId     string
+	clientSecret string
+	scopes       []string
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+type oauth2TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Error       string `json:"error"`
+}
+
+func (auth *oauth2Auth) authenticate(req *http.Request) error {
+	token, err := auth.getToken(req)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (auth *oauth2Auth) invalidate() {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	auth.token = ""
+}
+
+// Token is requested within context of request that needs it
+func (auth *oauth2Auth) getToken(target *http.Request) (string, error) {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	if auth.token != "" && (auth.expires.IsZero() || time.Now().Before(auth.expires)) {
+		return auth.token, nil
+	}
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(auth.scopes) > 0 {
+		form.Set("scope", strings.Join(auth.scopes, " "))
+	}
+	req, err := http.NewRequest("POST", auth.tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(auth.clientId), url.QueryEscape(auth.clientSecret))
+	resp, err := auth.client.Do(req.WithContext(target.Context()))
+	if err != nil {
+		return "", fmt.Errorf("oauth2 token request: %s", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	response := &oauth2TokenResponse{}
+	json.Unmarshal(body, response)
+	if resp.StatusCode != 200 || response.AccessToken == "" {
+		return "", fmt.Errorf("oauth2 token request: status %s %s", resp.Status, response.Error)
+	}
+	auth.token = response.AccessToken
+	auth.expires = time.Time{}
+	if response.ExpiresIn > 0 {
+		auth.expires = time.Now().Add(time.Duration(response.ExpiresIn)*time.Second - OAuth2ExpiryMargin)
+	}
+	return auth.token, nil
+}
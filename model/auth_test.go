@@ -0,0 +1,183 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Jolokia stand-in that accepts requests with expected header value only
+func startAuthJolokia(header string, accepted func(value string) bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !accepted(r.Header.Get(header)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"status": 200}`))
+	}))
+}
+
+// Token server that issues token-1, token-2, ... with given lifetime
+type tokenServer struct {
+	*httptest.Server
+	issued    int64
+	expiresIn int
+	mutex     sync.Mutex
+	forms     []string
+}
+
+func startTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	server := &tokenServer{expiresIn: expiresIn}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, ok := r.BasicAuth()
+		if !ok || clientId != "graph" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(&oauth2TokenResponse{Error: "invalid_client"})
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("could not parse token request: %s", err)
+		}
+		server.mutex.Lock()
+		server.forms = append(server.forms, r.PostForm.Encode())
+		server.mutex.Unlock()
+		issued := atomic.AddInt64(&server.issued, 1)
+		json.NewEncoder(w).Encode(&oauth2TokenResponse{
+			AccessToken: fmt.Sprintf("token-%v", issued),
+			TokenType:   "Bearer",
+			ExpiresIn:   server.expiresIn})
+	}))
+	return server
+}
+
+func TestBearerAuth(t *testing.T) {
+	server := startAuthJolokia("Authorization", func(value string) bool { return value == "Bearer abc" })
+	defer server.Close()
+	auth, err := newAuthenticator(&Authorization{Type: AUTH_BEARER, Token: "abc"}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := callEndpoint(context.Background(), server.Client(), server.URL, auth); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHeadersAuth(t *testing.T) {
+	server := startAuthJolokia("X-Api-Key", func(value string) bool { return value == "key" })
+	defer server.Close()
+	auth, err := newAuthenticator(&Authorization{Type: AUTH_HEADERS, Headers: map[string]string{"X-Api-Key": "key"}},
+		server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := callEndpoint(context.Background(), server.Client(), server.URL, auth); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBasicAuthIsDefault(t *testing.T) {
+	server := startAuthJolokia("Authorization", func(value string) bool { return value == "Basic c214OnNteA==" })
+	defer server.Close()
+	auth, err := newAuthenticator(&Authorization{Login: "smx", Pass: "smx"}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := callEndpoint(context.Background(), server.Client(), server.URL, auth); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOAuth2TokenIsCached(t *testing.T) {
+	tokens := startTokenServer(t, 3600)
+	defer tokens.Close()
+	server := startAuthJolokia("Authorization", func(value string) bool { return value == "Bearer token-1" })
+	defer server.Close()
+	auth, err := newAuthenticator(&Authorization{
+		Type:         AUTH_OAUTH2,
+		TokenUrl:     tokens.URL,
+		ClientId:     "graph",
+		ClientSecret: "secret",
+		Scopes:       []string{"jolokia", "read"}}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := callEndpoint(context.Background(), server.Client(), server.URL, auth); err != nil {
+			t.Fatalf("call %v: %s", i, err)
+		}
+	}
+	if issued := atomic.LoadInt64(&tokens.issued); issued != 1 {
+		t.Errorf("%v tokens are issued, cached one must be reused", issued)
+	}
+	if want := "grant_type=client_credentials&scope=jolokia+read"; tokens.forms[0] != want {
+		t.Errorf("token request = %s, want %s", tokens.forms[0], want)
+	}
+}
+
+func TestOAuth2TokenIsRefreshedBeforeExpiry(t *testing.T) {
+	// token is refreshed a second after it is issued, OAuth2ExpiryMargin before it expires
+	tokens := startTokenServer(t, int(OAuth2ExpiryMargin/time.Second)+1)
+	defer tokens.Close()
+	var lastToken atomic.Value
+	server := startAuthJolokia("Authorization", func(value string) bool {
+		lastToken.Store(value)
+		return true
+	})
+	defer server.Close()
+	auth, _ := newAuthenticator(&Authorization{
+		Type:         AUTH_OAUTH2,
+		TokenUrl:     tokens.URL,
+		ClientId:     "graph",
+		ClientSecret: "secret"}, server.Client())
+	if _, err := callEndpoint(context.Background(), server.Client(), server.URL, auth); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := callEndpoint(context.Background(), server.Client(), server.URL, auth); err != nil {
+		t.Fatal(err)
+	}
+	if token := lastToken.Load(); token != "Bearer token-2" {
+		t.Errorf("token = %v, want refreshed token-2", token)
+	}
+}
+
+func TestOAuth2TokenIsInvalidatedOn401(t *testing.T) {
+	tokens := startTokenServer(t, 3600)
+	defer tokens.Close()
+	// the first token is revoked
+	server := startAuthJolokia("Authorization", func(value string) bool { return value == "Bearer token-2" })
+	defer server.Close()
+	auth, _ := newAuthenticator(&Authorization{
+		Type:         AUTH_OAUTH2,
+		TokenUrl:     tokens.URL,
+		ClientId:     "graph",
+		ClientSecret: "secret"}, server.Client())
+	if _, err := callEndpoint(context.Background(), server.Client(), server.URL, auth); err == nil {
+		t.Fatal("revoked token must be rejected")
+	}
+	if _, err := callEndpoint(context.Background(), server.Client(), server.URL, auth); err != nil {
+		t.Fatalf("new token must be requested after 401: %s", err)
+	}
+	if issued := atomic.LoadInt64(&tokens.issued); issued != 2 {
+		t.Errorf("%v tokens are issued, want 2", issued)
+	}
+}
+
+func TestOAuth2TokenRequestFailure(t *testing.T) {
+	tokens := startTokenServer(t, 3600)
+	defer tokens.Close()
+	auth, _ := newAuthenticator(&Authorization{
+		Type:         AUTH_OAUTH2,
+		TokenUrl:     tokens.URL,
+		ClientId:     "graph",
+		ClientSecret: "wrong"}, tokens.Client())
+	if _, err := callEndpoint(context.Background(), tokens.Client(), tokens.URL, auth); err == nil {
+		t.Error("error is expected when token is not issued")
+	}
+}
@@ -32,6 +32,18 @@ type ReadResponse struct {
 	Status int    `json:"status,omitempty"`
 }
 
+// Operation of jolokia bulk request
+type JolokiaRequest struct {
+	Type      string        `json:"type"`
+	MBean     string        `json:"mbean"`
+	Operation string        `json:"operation,omitempty"`
+	Arguments []interface{} `json:"arguments,omitempty"`
+}
+
+func NewJolokiaExec(mbean string, operation string, arguments ...interface{}) *JolokiaRequest {
+	return &JolokiaRequest{Type: "exec", MBean: mbean, Operation: operation, Arguments: arguments}
+}
+
 type ReadRoutesEndpointsEntry struct {
 	Routes *map[string]*ReadRouteEndpointsEntry `json:"routes,omitempty"`
 }
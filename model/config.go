@@ -4,43 +4,332 @@ import (
 	"os"
 	"encoding/json"
 	"time"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"gopkg.in/yaml.v2"
+	"github.com/BurntSushi/toml"
 )
 
 type InstanceConfig struct {
+	PollingConfig
 	Created                      time.Time
 	Environments                 []*EnvironmentConfig
-	ServiceUpdateIntervalSeconds int
-	RouteUpdateIntervalSeconds   int
+	Metrics                      []*MetricSinkConfig
+	History                      *HistoryConfig
+	Topology                     *TopologyConfig
+	Alerts                       *AlertsConfig
+	Notifications                *NotificationsConfig
+	Access                       *AccessConfig
+	Redaction                    *RedactionConfig
+	EndpointRules                []*EndpointRuleConfig
+}
+
+// Rule of endpoint normalization, rules are applied in order and replace default ones:
+// rewrite replaces regexp pattern, alias renames schemes, local prefixes endpoints of schemes with service name,
+// query drops query parameters of schemes except listed ones, query rule without schemes applies to other schemes
+type EndpointRuleConfig struct {
+	Type        string
+	Pattern     string
+	Replacement string
+	Schemes     []string
+	Alias       string
+	Parameters  []string
+}
+
+// Masks secrets in endpoint uris and route schemas before they are stored, it is on by default
+type RedactionConfig struct {
+	Disabled bool
+	Mask     string
+	// query parameters masked in addition to defaults, name matches if it contains one of them
+	Parameters []string
+	// schema attributes masked entirely in addition to defaults
+	Attributes []string
+}
+
+// Login to camel-graph itself: basic auth with users file or user name set by trusted reverse proxy
+type AccessConfig struct {
+	UsersFile string
+	// header with user name set by reverse proxy, requests without it are rejected
+	ProxyHeader string
+	// optional header with comma separated roles set by reverse proxy
+	ProxyRolesHeader string
+	// roles of users that are not listed in users file
+	DefaultRoles []string
+	Roles        []*RoleConfig
+}
+
+// Role allows environments, all of them if list is empty, admin role also allows admin endpoints
+type RoleConfig struct {
+	Name         string
+	Environments []string
+	Admin        bool
+}
+
+// Users file: login, password as plain text or sha256:<hex> and roles
+type UsersConfig struct {
+	Users []*UserConfig
+}
+
+type UserConfig struct {
+	Login    string
+	Password string
+	Roles    []string
+}
+
+type NotificationsConfig struct {
+	Channels []*NotificationChannelConfig
+}
+
+// Notification channel: webhook, slack or email
+type NotificationChannelConfig struct {
+	Name string
+	Type string
+	// webhook and slack url
+	Url string
+	// text/template of webhook body, notification is passed as json if empty
+	Template string
+	Headers  map[string]string
+	Smtp     *SmtpConfig
+	To       []string
+	// only notifications of these environments and types are sent, all if empty
+	Environments []string
+	Events       []string
+	MaxPerMinute int
+}
+
+type SmtpConfig struct {
+	Host  string
+	Port  int
+	Login string
+	Pass  string
+	From  string
+}
+
+type AlertsConfig struct {
+	EvaluationIntervalSeconds int
+	Rules                     []*AlertRuleConfig
+}
+
+// Alert rule: route_state, route_failure_rate, route_inflight or service_failed.
+// Environment, Service and Route narrow rule targets, all of them are checked if empty.
+type AlertRuleConfig struct {
+	Name        string
+	Type        string
+	Severity    string
+	Environment string
+	Service     string
+	Route       string
+	// expected route state for route_state, Started by default
+	State string
+	// limit for route_failure_rate (failures per second) and route_inflight
+	Threshold float64
+	// consecutive failed updates for service_failed, 1 by default
+	Updates int
+	// how long condition must hold before alert fires
+	ForSeconds int
+}
+
+// Local history of route counters, disabled if absent
+type HistoryConfig struct {
+	File           string
+	RetentionHours int
+}
+
+// Snapshots of environment topology stored on every change
+type TopologyConfig struct {
+	File          string
+	RetentionDays int
+}
+
+// Metric sink: graphite, prometheus, file or statsd
+type MetricSinkConfig struct {
+	Type string
+	// host:port for graphite and statsd
+	Url string
+	// http path for prometheus
+	Path string
+	// file for file sink
+	File             string
+	BufferSize       int
+	RepeatSendOnFail bool
 }
 
 type EnvironmentConfig struct {
+	PollingConfig
 	Name     string
 	Services []*ServiceConfig
 }
 
 type ServiceConfig struct {
+	PollingConfig
 	Name          string
 	Url           string
 	Color         string
 	Authorization *Authorization
+	Transport     *TransportConfig
+}
+
+// Connection settings of service, proxy is taken from HTTP_PROXY variables if not set
+type TransportConfig struct {
+	CaFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+	Proxy              string
+	MaxIdleConns       int
+}
+
+// Polling settings, service values override environment ones and environment values override instance ones
+type PollingConfig struct {
+	ServiceUpdateIntervalSeconds int
+	RouteUpdateIntervalSeconds   int
+	TimeoutSeconds               int
+	Retry                        *RetryConfig
+	// routes are updated by bulk requests of RouteBatchSize routes, RouteWorkers requests at once
+	RouteWorkers                 int
+	RouteBatchSize               int
+	// removed routes are kept for this time in case they come back
+	RouteGracePeriodSeconds      int
 }
 
+// Failed requests are repeated up to Attempts times in total, backoff doubles after every attempt
+type RetryConfig struct {
+	Attempts         int
+	BackoffMillis    int
+	MaxBackoffMillis int
+}
+
+// Jolokia authorization: basic (default), bearer, headers or oauth2, headers are sent with every type
 type Authorization struct {
+	Type  string
 	Login string
 	Pass  string
+	// static bearer token
+	Token   string
+	Headers map[string]string
+	// oauth2 client credentials
+	TokenUrl     string
+	ClientId     string
+	ClientSecret string
+	Scopes       []string
 }
 
-// Read config
+// Read config, format is detected by extension: json, yaml/yml or toml.
+// ${NAME} and ${NAME:-default} in string values are replaced with environment variables.
 func ReadConfig(fileName string) (*InstanceConfig, error) {
-	rootConfig := InstanceConfig{}
-	file, err := os.Open(fileName)
+	problems := &configProblems{}
+	tree, err := readConfigTree(fileName, problems)
 	if err != nil {
 		return nil, err
 	}
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&rootConfig)
+	if err = problems.err(); err != nil {
+		return nil, err
+	}
+	return decodeConfig(tree)
+}
+
+// Reads config file as generic tree with interpolated variables, variables that are not set are added to problems
+func readConfigTree(fileName string, problems *configProblems) (interface{}, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+		tree = normalizeYaml(tree)
+	case ".toml":
+		tree = make(map[string]interface{})
+		_, err = toml.Decode(string(data), &tree)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&tree)
+	}
 	if err != nil {
 		return nil, err
 	}
+	return interpolate(tree, "$", problems), nil
+}
+
+// Every format is decoded as json to keep field matching the same
+func decodeConfig(tree interface{}) (*InstanceConfig, error) {
+	rootConfig := InstanceConfig{}
+	if err := decodeTree(tree, &rootConfig); err != nil {
+		return nil, err
+	}
 	return &rootConfig, nil
 }
+
+func decodeTree(tree interface{}, target interface{}) error {
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// $${NAME} is not replaced, it is left as ${NAME}
+var variablePattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Replaces variables in every string of the tree except replacements of endpoint rules, where ${name} refers
+// to regexp group. Variables that are not set are reported as problems at their json path.
+func interpolate(tree interface{}, path string, problems *configProblems) interface{} {
+	switch value := tree.(type) {
+	case string:
+		return variablePattern.ReplaceAllStringFunc(value, func(match string) string {
+			if strings.HasPrefix(match, "$$") {
+				return match[1:]
+			}
+			groups := variablePattern.FindStringSubmatch(match)
+			if v, exists := os.LookupEnv(groups[1]); exists {
+				return v
+			}
+			if groups[2] != "" {
+				return groups[3]
+			}
+			problems.add(path, "environment variable %s is not set", groups[1])
+			return match
+		})
+	case map[string]interface{}:
+		for k, v := range value {
+			if strings.EqualFold(k, "replacement") && endpointRulePath.MatchString(path) {
+				continue
+			}
+			value[k] = interpolate(v, path+"."+k, problems)
+		}
+	case []interface{}:
+		for i, v := range value {
+			value[i] = interpolate(v, fmt.Sprintf("%s[%v]", path, i), problems)
+		}
+	case []map[string]interface{}:
+		for i, v := range value {
+			interpolate(v, fmt.Sprintf("%s[%v]", path, i), problems)
+		}
+	}
+	return tree
+}
+
+var endpointRulePath = regexp.MustCompile(`(?i)^\$\.endpointRules\[\d+\]$`)
+
+// yaml maps have interface keys which json can not marshal
+func normalizeYaml(tree interface{}) interface{} {
+	switch value := tree.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			result[fmt.Sprintf("%v", k)] = normalizeYaml(v)
+		}
+		return result
+	case []interface{}:
+		for i, v := range value {
+			value[i] = normalizeYaml(v)
+		}
+	}
+	return tree
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("CAMEL_GRAPH_TEST_PASS", "secret")
+	defer os.Unsetenv("CAMEL_GRAPH_TEST_PASS")
+	tree := map[string]interface{}{
+		"environments": []interface{}{
+			map[string]interface{}{"services": []interface{}{
+				map[string]interface{}{
+					"pass":  "${CAMEL_GRAPH_TEST_PASS}",
+					"login": "${CAMEL_GRAPH_TEST_LOGIN:-smx}",
+					"url":   "http://$${HOST}:${CAMEL_GRAPH_TEST_MISSING}"}}}},
+		"endpointRules": []interface{}{
+			map[string]interface{}{"type": "rewrite", "pattern": "^(?P<name>.*)$", "replacement": "${name}"}}}
+	problems := &configProblems{}
+	interpolate(tree, "$", problems)
+
+	service := tree["environments"].([]interface{})[0].(map[string]interface{})["services"].([]interface{})[0].(map[string]interface{})
+	want := map[string]interface{}{
+		"pass":  "secret",
+		"login": "smx",
+		"url":   "http://${HOST}:${CAMEL_GRAPH_TEST_MISSING}"}
+	if !reflect.DeepEqual(service, want) {
+		t.Errorf("service = %v, want %v", service, want)
+	}
+	rule := tree["endpointRules"].([]interface{})[0].(map[string]interface{})
+	if rule["replacement"] != "${name}" {
+		t.Errorf("replacement = %v, must not be interpolated", rule["replacement"])
+	}
+	wantProblems := configProblems{{
+		Path:    "$.environments[0].services[0].url",
+		Message: "environment variable CAMEL_GRAPH_TEST_MISSING is not set"}}
+	if !reflect.DeepEqual(*problems, wantProblems) {
+		t.Errorf("problems = %v, want %v", *problems, wantProblems)
+	}
+}
+
+func TestValidateConfigReturnsValidatedConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "camel-graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "services.json")
+	err = ioutil.WriteFile(fileName, []byte(`{"environments": [{"name": "dev",
+		"services": [{"name": "smx", "url": "http://localhost:8181", "color": "#fff"}]}]}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, problems, err := ValidateConfig(fileName)
+	if err != nil || len(problems) > 0 {
+		t.Fatalf("unexpected error %v or problems %v", err, problems)
+	}
+	if len(config.Environments) != 1 || config.Environments[0].Services[0].Color != "#fff" {
+		t.Errorf("config is not decoded: %+v", config)
+	}
+
+	if err = ioutil.WriteFile(fileName, []byte(`{"environments": "dev"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, problems, err = ValidateConfig(fileName)
+	if err != nil || len(problems) == 0 || config != nil {
+		t.Errorf("config %v must not be decoded, problems %v, error %v", config, problems, err)
+	}
+}
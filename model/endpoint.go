@@ -0,0 +1,126 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	ENDPOINT_RULE_REWRITE = "rewrite"
+	ENDPOINT_RULE_ALIAS   = "alias"
+	ENDPOINT_RULE_LOCAL   = "local"
+	ENDPOINT_RULE_QUERY   = "query"
+)
+
+// Rules that are used if config has none, they keep nodes of graph as they were before rules were configurable
+var DefaultEndpointRules = []*EndpointRuleConfig{
+	{Type: ENDPOINT_RULE_ALIAS, Schemes: []string{"activemq"}, Alias: "jms"},
+	{Type: ENDPOINT_RULE_QUERY},
+	{Type: ENDPOINT_RULE_REWRITE, Pattern: "^.*?VirtualTopic", Replacement: "VirtualTopic"},
+	{Type: ENDPOINT_RULE_LOCAL, Schemes: []string{"direct", "direct-vm", "timer"}}}
+
+// Turns endpoint uri into node name, the same endpoint of different routes must get the same name
+type endpointNormalizer struct {
+	rules []endpointRule
+}
+
+type endpointRule func(serviceName string, endpoint string) string
+
+func newEndpointNormalizer(configs []*EndpointRuleConfig) (*endpointNormalizer, error) {
+	if len(configs) == 0 {
+		configs = DefaultEndpointRules
+	}
+	// query rule without schemes does not touch schemes that have own query rules
+	querySchemes := make([]string, 0)
+	for _, config := range configs {
+		if config.Type == ENDPOINT_RULE_QUERY {
+			querySchemes = append(querySchemes, config.Schemes...)
+		}
+	}
+	normalizer := &endpointNormalizer{}
+	for i, config := range configs {
+		rule, err := newEndpointRule(config, querySchemes)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint rule %v: %s", i, err)
+		}
+		normalizer.rules = append(normalizer.rules, rule)
+	}
+	return normalizer, nil
+}
+
+func newEndpointRule(config *EndpointRuleConfig, querySchemes []string) (endpointRule, error) {
+	switch config.Type {
+	case ENDPOINT_RULE_REWRITE:
+		pattern, err := regexp.Compile(config.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return func(serviceName string, endpoint string) string {
+			return pattern.ReplaceAllString(endpoint, config.Replacement)
+		}, nil
+	case ENDPOINT_RULE_ALIAS:
+		if config.Alias == "" || len(config.Schemes) == 0 {
+			return nil, fmt.Errorf("schemes and alias must not be empty")
+		}
+		return func(serviceName string, endpoint string) string {
+			if contains(config.Schemes, endpointScheme(endpoint)) {
+				return config.Alias + endpoint[strings.Index(endpoint, ":"):]
+			}
+			return endpoint
+		}, nil
+	case ENDPOINT_RULE_LOCAL:
+		if len(config.Schemes) == 0 {
+			return nil, fmt.Errorf("schemes must not be empty")
+		}
+		return func(serviceName string, endpoint string) string {
+			if contains(config.Schemes, endpointScheme(endpoint)) {
+				return serviceName + ":" + endpoint
+			}
+			return endpoint
+		}, nil
+	case ENDPOINT_RULE_QUERY:
+		return func(serviceName string, endpoint string) string {
+			i := strings.Index(endpoint, "?")
+			if i < 0 {
+				return endpoint
+			}
+			scheme := endpointScheme(endpoint)
+			if len(config.Schemes) > 0 && !contains(config.Schemes, scheme) ||
+				len(config.Schemes) == 0 && contains(querySchemes, scheme) {
+				return endpoint
+			}
+			kept := make([]string, 0)
+			for _, parameter := range strings.Split(endpoint[i+1:], "&") {
+				if contains(config.Parameters, strings.SplitN(parameter, "=", 2)[0]) {
+					kept = append(kept, parameter)
+				}
+			}
+			if len(kept) == 0 {
+				return endpoint[:i]
+			}
+			return endpoint[:i+1] + strings.Join(kept, "&")
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown endpoint rule type %q", config.Type)
+	}
+}
+
+// Uri is decoded and scheme separator is unified before rules are applied
+func (normalizer *endpointNormalizer) normalize(serviceName string, endpoint string) string {
+	endpoint = strings.Replace(endpoint, "%7B", "{", -1)
+	endpoint = strings.Replace(endpoint, "%7D", "}", -1)
+	endpoint = strings.Replace(endpoint, "://", ":", -1)
+	for _, rule := range normalizer.rules {
+		endpoint = rule(serviceName, endpoint)
+	}
+	return endpoint
+}
+
+func endpointScheme(endpoint string) string {
+	i := strings.Index(endpoint, ":")
+	if i < 0 {
+		return ""
+	}
+	return endpoint[:i]
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+// Normalization of endpoints before rules were configurable
+func legacyCleanEndpoint(serviceName string, endpoint string) string {
+	endpoint = strings.Replace(endpoint, "%7B", "{", -1)
+	endpoint = strings.Replace(endpoint, "%7D", "}", -1)
+	endpoint = strings.Replace(endpoint, "://", ":", -1)
+	endpoint = strings.Replace(endpoint, "activemq:", "jms:", -1)
+	endpoint = strings.Split(endpoint, "?")[0]
+	if strings.Contains(endpoint, "VirtualTopic") {
+		endpoint = "VirtualTopic" + strings.Split(endpoint, "VirtualTopic")[1]
+	}
+	if strings.HasPrefix(endpoint, "direct") || strings.HasPrefix(endpoint, "timer") {
+		endpoint = serviceName + ":" + endpoint
+	}
+	return endpoint
+}
+
+func TestDefaultEndpointRulesMatchLegacyCleanEndpoint(t *testing.T) {
+	normalizer, err := newEndpointNormalizer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		endpoint string
+		node     string
+	}{
+		{"activemq://queue:orders?concurrentConsumers=5", "jms:queue:orders"},
+		{"activemq:queue:orders", "jms:queue:orders"},
+		{"jms:queue:orders", "jms:queue:orders"},
+		{"jms:queue:Consumer.a.VirtualTopic.orders", "VirtualTopic.orders"},
+		{"activemq:queue:Consumer.b.VirtualTopic.orders?concurrentConsumers=5", "VirtualTopic.orders"},
+		{"activemq:topic:VirtualTopic.orders", "VirtualTopic.orders"},
+		{"direct://start", "smx:direct:start"},
+		{"direct:start", "smx:direct:start"},
+		{"direct-vm://start", "smx:direct-vm:start"},
+		{"timer://tick?period=1000", "smx:timer:tick"},
+		{"timer:tick", "smx:timer:tick"},
+		{"direct:%7Bheader.target%7D", "smx:direct:{header.target}"},
+		{"jms:queue:%7Bheader.queue%7D?transacted=true", "jms:queue:{header.queue}"},
+		{"http://localhost:8080/api?bridgeEndpoint=true&throwExceptionOnFailure=false", "http:localhost:8080/api"},
+		{"file://inbox?delete=true", "file:inbox"},
+		{"log:orders", "log:orders"},
+	} {
+		if node := normalizer.normalize("smx", test.endpoint); node != test.node {
+			t.Errorf("%s: node = %q, want %q", test.endpoint, node, test.node)
+		}
+		if legacy := legacyCleanEndpoint("smx", test.endpoint); legacy != test.node {
+			t.Errorf("%s: legacy node = %q, want %q", test.endpoint, legacy, test.node)
+		}
+	}
+}
+
+// Rules for sjms2, amqp, kafka and seda documented in readme
+func TestDocumentedEndpointRules(t *testing.T) {
+	normalizer, err := newEndpointNormalizer([]*EndpointRuleConfig{
+		{Type: ENDPOINT_RULE_ALIAS, Schemes: []string{"activemq", "sjms2", "amqp"}, Alias: "jms"},
+		{Type: ENDPOINT_RULE_QUERY, Schemes: []string{"kafka"}, Parameters: []string{"groupId"}},
+		{Type: ENDPOINT_RULE_QUERY},
+		{Type: ENDPOINT_RULE_REWRITE, Pattern: "^.*?VirtualTopic", Replacement: "VirtualTopic"},
+		{Type: ENDPOINT_RULE_LOCAL, Schemes: []string{"direct", "direct-vm", "timer", "seda"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		endpoint string
+		node     string
+	}{
+		{"activemq://queue:orders?concurrentConsumers=5", "jms:queue:orders"},
+		{"sjms2://queue:orders?consumerCount=5", "jms:queue:orders"},
+		{"amqp:topic:prices", "jms:topic:prices"},
+		{"sjms2:queue:Consumer.a.VirtualTopic.orders", "VirtualTopic.orders"},
+		{"kafka:orders?brokers=localhost:9092&groupId=billing", "kafka:orders?groupId=billing"},
+		{"kafka://orders?groupId=billing&autoOffsetReset=earliest", "kafka:orders?groupId=billing"},
+		{"kafka:orders?brokers=localhost:9092", "kafka:orders"},
+		{"seda://work?size=100", "smx:seda:work"},
+		{"direct-vm:start", "smx:direct-vm:start"},
+		{"http://localhost:8080/api?bridgeEndpoint=true", "http:localhost:8080/api"},
+	} {
+		if node := normalizer.normalize("smx", test.endpoint); node != test.node {
+			t.Errorf("%s: node = %q, want %q", test.endpoint, node, test.node)
+		}
+	}
+}
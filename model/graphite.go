@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"log"
 	"fmt"
 	"net"
@@ -12,6 +13,9 @@ type Graphite struct {
 	pool             *pool.Pool
 	metrics          chan *Metric
 	repeatSendOnFail bool
+	// closed on shutdown to stop waiting between repeats
+	closing chan struct{}
+	done    chan struct{}
 }
 
 const (
@@ -27,23 +31,49 @@ func NewGraphite(url string, repeatSendOnFail bool) *Graphite {
 		panic(fmt.Sprintf("Error during creating new pool for graphite %v", err))
 	}
 
-	graphite := &Graphite{pool: &pool, metrics: make(chan *Metric, 1000), repeatSendOnFail: repeatSendOnFail}
+	graphite := &Graphite{
+		pool:             &pool,
+		metrics:          make(chan *Metric, 1000),
+		repeatSendOnFail: repeatSendOnFail,
+		closing:          make(chan struct{}),
+		done:             make(chan struct{})}
 	go func() {
-		for {
-			select {
-			case metric := <-graphite.metrics:
-				times := 1
-				for err := graphite.send(metric); err != nil && times < 4; {
-					sleepTime := SleepDuration * time.Duration(times)
-					log.Println(fmt.Sprintf("Will sleep for %v (%v attempt of 3)", sleepTime, times))
-					time.Sleep(sleepTime * time.Second)
-					times++
-				}
-			}
+		defer close(graphite.done)
+		for metric := range graphite.metrics {
+			graphite.sendRepeating(metric)
 		}
 	}()
 	return graphite
 }
+
+// Repeats failed send 3 times with growing pauses, repeats are skipped on shutdown
+func (graphite *Graphite) sendRepeating(metric *Metric) {
+	err := graphite.send(metric)
+	for times := 1; err != nil && times < 4; times++ {
+		sleepTime := SleepDuration * time.Duration(times)
+		log.Println(fmt.Sprintf("Will sleep for %v (%v attempt of 3)", sleepTime, times))
+		select {
+		case <-time.After(sleepTime * time.Second):
+		case <-graphite.closing:
+			log.Println(fmt.Sprintf("Graphite is closing, metric will be lost"))
+			return
+		}
+		err = graphite.send(metric)
+	}
+}
+
+// Sends buffered metrics without repeats and closes connections
+func (graphite *Graphite) Close(ctx context.Context) error {
+	close(graphite.closing)
+	close(graphite.metrics)
+	select {
+	case <-graphite.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	(*graphite.pool).Close()
+	return nil
+}
 func (graphite *Graphite) send(metric *Metric) error {
 	message := fmt.Sprintf("%s %v %v", metric.name, metric.value, metric.time.Unix())
 
@@ -0,0 +1,248 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	DefaultHistoryFile           = "history.db"
+	DefaultHistoryRetentionHours = 7 * 24
+	HistoryCleanupInterval       = time.Hour
+)
+
+// Local history of route counters kept in embedded bolt store.
+// Points are stored in nested buckets environment -> service -> context -> route keyed by poll time.
+type History struct {
+	db        *bolt.DB
+	retention time.Duration
+	stop      chan struct{}
+}
+
+type HistoryPoint struct {
+	Time                JsonTime `json:"time"`
+	State               string   `json:"state,omitempty"`
+	ExchangesTotal      int      `json:"exchangesTotal"`
+	ExchangesCompleted  int      `json:"exchangesCompleted"`
+	ExchangesFailed     int      `json:"exchangesFailed"`
+	ExchangesInflight   int      `json:"exchangesInflight"`
+	MaxProcessingTime   int      `json:"maxProcessingTime"`
+	MinProcessingTime   int      `json:"minProcessingTime"`
+	LastProcessingTime  int      `json:"lastProcessingTime"`
+	MeanProcessingTime  int      `json:"meanProcessingTime"`
+	TotalProcessingTime int      `json:"totalProcessingTime"`
+	FailuresHandled     int      `json:"failuresHandled"`
+	Redeliveries        int      `json:"redeliveries"`
+}
+
+type HistorySeries struct {
+	Environment string          `json:"environment"`
+	Service     string          `json:"service"`
+	Context     string          `json:"context"`
+	Route       string          `json:"route"`
+	Points      []*HistoryPoint `json:"points"`
+}
+
+// history entry of one route within one poll
+type historyRecord struct {
+	context string
+	route   string
+	point   *HistoryPoint
+}
+
+func NewHistory(config *HistoryConfig) (*History, error) {
+	file := config.File
+	if file == "" {
+		file = DefaultHistoryFile
+	}
+	retentionHours := config.RetentionHours
+	if retentionHours <= 0 {
+		retentionHours = DefaultHistoryRetentionHours
+	}
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	history := &History{db: db, retention: time.Duration(retentionHours) * time.Hour, stop: make(chan struct{})}
+	go history.cleanup()
+	return history, nil
+}
+
+func newHistoryPoint(e *ReadRouteEntry, t time.Time) *HistoryPoint {
+	return &HistoryPoint{
+		Time:                JsonTime(t),
+		State:               e.State,
+		ExchangesTotal:      e.ExchangesTotal,
+		ExchangesCompleted:  e.ExchangesCompleted,
+		ExchangesFailed:     e.ExchangesFailed,
+		ExchangesInflight:   e.ExchangesInflight,
+		MaxProcessingTime:   e.MaxProcessingTime,
+		MinProcessingTime:   e.MinProcessingTime,
+		LastProcessingTime:  e.LastProcessingTime,
+		MeanProcessingTime:  e.MeanProcessingTime,
+		TotalProcessingTime: e.TotalProcessingTime,
+		FailuresHandled:     e.FailuresHandled,
+		Redeliveries:        e.Redeliveries}
+}
+
+// Stores points of one service poll in a single transaction
+func (history *History) record(environment string, service string, records []*historyRecord) error {
+	return history.db.Update(func(tx *bolt.Tx) error {
+		for _, record := range records {
+			// bucket names must not be empty, one such route does not cost history of the others
+			if record.context == "" || record.route == "" {
+				log.Printf("error: %s:%s history of route %q of context %q is not recorded: empty name",
+					environment, service, record.route, record.context)
+				continue
+			}
+			bucket, err := createBuckets(tx, environment, service, record.context, record.route)
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(record.point)
+			if err != nil {
+				return err
+			}
+			if err = bucket.Put(historyKey(time.Time(record.point.Time)), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Returns series of routes with given id, context is optional
+func (history *History) Query(environment string, service string, context string, route string,
+	from time.Time, to time.Time) ([]*HistorySeries, error) {
+	result := make([]*HistorySeries, 0)
+	err := history.db.View(func(tx *bolt.Tx) error {
+		serviceBucket := findBucket(tx, environment, service)
+		if serviceBucket == nil {
+			return nil
+		}
+		return serviceBucket.ForEach(func(contextName []byte, v []byte) error {
+			// only nested buckets have nil values
+			if v != nil || (context != "" && string(contextName) != context) {
+				return nil
+			}
+			routeBucket := serviceBucket.Bucket(contextName).Bucket([]byte(route))
+			if routeBucket == nil {
+				return nil
+			}
+			series := &HistorySeries{
+				Environment: environment,
+				Service:     service,
+				Context:     string(contextName),
+				Route:       route,
+				Points:      make([]*HistoryPoint, 0)}
+			max := historyKey(to)
+			cursor := routeBucket.Cursor()
+			for k, v := cursor.Seek(historyKey(from)); k != nil && bytes.Compare(k, max) <= 0; k, v = cursor.Next() {
+				point := &HistoryPoint{}
+				if err := json.Unmarshal(v, point); err != nil {
+					return err
+				}
+				series.Points = append(series.Points, point)
+			}
+			result = append(result, series)
+			return nil
+		})
+	})
+	return result, err
+}
+
+// Removes points that are older than retention
+func (history *History) cleanup() {
+	ticker := time.NewTicker(HistoryCleanupInterval)
+	defer ticker.Stop()
+	for {
+		deadline := historyKey(time.Now().Add(-history.retention))
+		err := history.db.Update(func(tx *bolt.Tx) error {
+			return tx.ForEach(func(name []byte, environmentBucket *bolt.Bucket) error {
+				return forEachRouteBucket(environmentBucket, func(routeBucket *bolt.Bucket) error {
+					// keys are collected first, deleting while iterating makes cursor skip entries
+					expired := make([][]byte, 0)
+					cursor := routeBucket.Cursor()
+					for k, _ := cursor.First(); k != nil && bytes.Compare(k, deadline) < 0; k, _ = cursor.Next() {
+						expired = append(expired, k)
+					}
+					for _, k := range expired {
+						if err := routeBucket.Delete(k); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+			})
+		})
+		if err != nil {
+			log.Printf("error: could not clean up history: %s", err)
+		}
+		select {
+		case <-history.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stops cleanup and closes store, it must be called after pollers are stopped
+func (history *History) Close() error {
+	close(history.stop)
+	return history.db.Close()
+}
+
+func forEachRouteBucket(environmentBucket *bolt.Bucket, fn func(*bolt.Bucket) error) error {
+	return environmentBucket.ForEach(func(serviceName []byte, v []byte) error {
+		if v != nil {
+			return nil
+		}
+		serviceBucket := environmentBucket.Bucket(serviceName)
+		return serviceBucket.ForEach(func(contextName []byte, v []byte) error {
+			if v != nil {
+				return nil
+			}
+			contextBucket := serviceBucket.Bucket(contextName)
+			return contextBucket.ForEach(func(routeName []byte, v []byte) error {
+				if v != nil {
+					return nil
+				}
+				return fn(contextBucket.Bucket(routeName))
+			})
+		})
+	})
+}
+
+func createBuckets(tx *bolt.Tx, names ...string) (*bolt.Bucket, error) {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(names[0]))
+	for _, name := range names[1:] {
+		if err != nil {
+			return nil, err
+		}
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(name))
+	}
+	return bucket, err
+}
+
+func findBucket(tx *bolt.Tx, names ...string) *bolt.Bucket {
+	bucket := tx.Bucket([]byte(names[0]))
+	for _, name := range names[1:] {
+		if bucket == nil {
+			return nil
+		}
+		bucket = bucket.Bucket([]byte(name))
+	}
+	return bucket
+}
+
+// Big endian unix nanos keep keys sorted by time
+func historyKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
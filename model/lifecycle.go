@@ -0,0 +1,45 @@
+package model
+
+import (
+	"log"
+	"time"
+)
+
+// Route that is not present in the last response stays in RouteMap with state None until grace period is over,
+// it is not polled. If it comes back within grace period it is taken as a new one but keeps FirstSeen.
+
+const DefaultRouteGracePeriodSeconds = 3600
+
+// Marks route which is not present in response as removed, removal time is kept from the first miss
+func (route *Route) remove(t time.Time) {
+	if route.RemovedAt == nil {
+		removedAt := JsonTime(t)
+		route.RemovedAt = &removedAt
+	}
+}
+
+// Brings removed route back, endpoints and schema are requested again as route could be changed
+func (route *Route) revive(endpointUri string) {
+	route.RemovedAt = nil
+	route.EndpointUri = endpointUri
+	route.Endpoints = &Endpoints{
+		Inputs:  []string{cleanEndpoint(route, endpointUri)},
+		Outputs: make([]string, 0)}
+	route.Schema = ""
+	route.Error = ""
+	route.UpdatingState = UPDATE_STATE_IN_PROCESS
+	// rates are not computed across absence
+	route.lastSample = nil
+}
+
+// Removes routes which are absent longer than grace period
+func (service *Service) evictRoutes(t time.Time) {
+	deadline := t.Add(-service.policy.routeGracePeriod)
+	for name, route := range service.RouteMap {
+		if route.RemovedAt != nil && time.Time(*route.RemovedAt).Before(deadline) {
+			delete(service.RouteMap, name)
+			log.Printf("info:  %s:%s route %s is evicted, it is absent since %s", service.environment.Name,
+				service.Name, route.Name, time.Time(*route.RemovedAt).Format(time.RFC3339))
+		}
+	}
+}
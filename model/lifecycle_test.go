@@ -0,0 +1,97 @@
+package model
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRouteLifecycle(t *testing.T) {
+	var mutex sync.Mutex
+	present := []string{"a", "b"}
+	setRoutes := func(routes ...string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		present = routes
+	}
+	server := httptest.NewServer(jolokiaHandler(t, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return present
+	}))
+	defer server.Close()
+	var consumer MetricConsumer = &MetricConsumerStub{}
+	environment, err := newEnvironment(context.Background(),
+		&InstanceConfig{PollingConfig: PollingConfig{RouteGracePeriodSeconds: 60}},
+		&EnvironmentConfig{Name: "dev", Services: []*ServiceConfig{{Name: "smx", Url: server.URL}}},
+		&consumer, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := environment.ServiceMap["smx"]
+	defer service.Stop()
+	poll := func(t0 time.Time) []*Route {
+		added, err := service.update(t0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.updateRoutes(t0, added)
+		return added
+	}
+	start := time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	if added := poll(at(0)); len(added) != 2 {
+		t.Fatalf("added = %v, want a and b", added)
+	}
+	b := service.RouteMap["camel.b"]
+	if len(b.Endpoints.Outputs) == 0 || b.UpdatingState != UPDATE_STATE_DONE {
+		t.Fatalf("route b is not polled: %+v", b)
+	}
+
+	// b is missed twice, removal time is kept from the first miss
+	setRoutes("a")
+	poll(at(10))
+	poll(at(20))
+	if b.RemovedAt == nil || !time.Time(*b.RemovedAt).Equal(at(10)) || b.State != NONE {
+		t.Fatalf("route b removedAt = %v, state = %s, want %v and None", b.RemovedAt, b.State, at(10))
+	}
+	for _, route := range service.dueRoutes(at(1000)) {
+		if route == b {
+			t.Error("removed route is polled")
+		}
+	}
+
+	// b comes back as the same route that is polled again
+	setRoutes("a", "b")
+	added := poll(at(30))
+	if len(added) != 1 || added[0] != b || service.RouteMap["camel.b"] != b {
+		t.Fatalf("added = %v, want route b back", added)
+	}
+	if b.RemovedAt != nil || !time.Time(b.FirstSeen).Equal(at(0)) || b.State != "Started" {
+		t.Errorf("revived route b = %+v, want firstSeen %v", b, at(0))
+	}
+	if len(b.Endpoints.Outputs) == 0 || b.UpdatingState != UPDATE_STATE_DONE ||
+		!time.Time(b.LastUpdated).Equal(at(30)) {
+		t.Errorf("revived route b is not polled: %+v", b)
+	}
+
+	// b is evicted only after grace period
+	setRoutes("a")
+	poll(at(40))
+	poll(at(100))
+	if service.RouteMap["camel.b"] != b {
+		t.Fatal("route b is evicted within grace period")
+	}
+	poll(at(101))
+	if _, exists := service.RouteMap["camel.b"]; exists {
+		t.Error("route b is not evicted after grace period")
+	}
+	if service.RouteMap["camel.a"] == nil {
+		t.Error("route a is evicted")
+	}
+}
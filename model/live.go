@@ -0,0 +1,171 @@
+package model
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	LIVE_ROUTES_ADDED    = "routes_added"
+	LIVE_ROUTES_UPDATED  = "routes_updated"
+	LIVE_ROUTES_REMOVED  = "routes_removed"
+	LIVE_ROUTES_EVICTED  = "routes_evicted"
+	LIVE_SERVICE_UPDATED = "service_updated"
+
+	DefaultLiveUpdatesBufferSize = 100
+	LiveUpdatesHeartbeat         = 30 * time.Second
+)
+
+// Changes of service state since its previous publication. Routes are keyed as in RouteMap,
+// schema of updated route is left out if it is not changed.
+type LiveUpdate struct {
+	Type        string            `json:"type"`
+	Environment string            `json:"environment"`
+	Service     string            `json:"service"`
+	Routes      map[string]*Route `json:"routes,omitempty"`
+	// keys of evicted routes
+	Keys []string `json:"keys,omitempty"`
+	// service state without routes
+	State *Service `json:"state,omitempty"`
+}
+
+// Passes changes of published service states to subscribers of environment.
+// Subscriber that does not keep up is dropped, its channel is closed so it can reconnect and read full state.
+type LiveUpdates struct {
+	mutex       sync.RWMutex
+	subscribers map[*liveSubscriber]bool
+	closed      bool
+}
+
+type liveSubscriber struct {
+	environment string
+	updates     chan *LiveUpdate
+}
+
+func NewLiveUpdates() *LiveUpdates {
+	return &LiveUpdates{subscribers: make(map[*liveSubscriber]bool)}
+}
+
+// Returns channel of environment updates and function that stops them
+func (it *LiveUpdates) Subscribe(environment string) (<-chan *LiveUpdate, func()) {
+	subscriber := &liveSubscriber{
+		environment: environment,
+		updates:     make(chan *LiveUpdate, DefaultLiveUpdatesBufferSize)}
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	if it.closed {
+		close(subscriber.updates)
+	} else {
+		it.subscribers[subscriber] = true
+	}
+	return subscriber.updates, func() {
+		it.mutex.Lock()
+		defer it.mutex.Unlock()
+		it.drop(subscriber)
+	}
+}
+
+// Closes channels of all subscribers, it is called on shutdown so streams are finished
+func (it *LiveUpdates) Close() {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.closed = true
+	for subscriber := range it.subscribers {
+		it.drop(subscriber)
+	}
+}
+
+func (it *LiveUpdates) drop(subscriber *liveSubscriber) {
+	if it.subscribers[subscriber] {
+		delete(it.subscribers, subscriber)
+		close(subscriber.updates)
+	}
+}
+
+func (it *LiveUpdates) watched(environment string) bool {
+	if it == nil {
+		return false
+	}
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	for subscriber := range it.subscribers {
+		if subscriber.environment == environment {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *LiveUpdates) send(update *LiveUpdate) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	for subscriber := range it.subscribers {
+		if subscriber.environment != update.Environment {
+			continue
+		}
+		select {
+		case subscriber.updates <- update:
+		default:
+			it.drop(subscriber)
+		}
+	}
+}
+
+// Sends differences of two published states of service
+func (it *LiveUpdates) sendChanges(environment string, previous *Service, current *Service) {
+	newUpdate := func(updateType string) *LiveUpdate {
+		return &LiveUpdate{
+			Type:        updateType,
+			Environment: environment,
+			Service:     current.Name,
+			Routes:      make(map[string]*Route)}
+	}
+	added, updated, removed := newUpdate(LIVE_ROUTES_ADDED), newUpdate(LIVE_ROUTES_UPDATED), newUpdate(LIVE_ROUTES_REMOVED)
+	for key, route := range current.RouteMap {
+		old, exists := previous.RouteMap[key]
+		switch {
+		case !exists:
+			added.Routes[key] = route
+		case reflect.DeepEqual(old, route):
+		case route.RemovedAt != nil && old.RemovedAt == nil:
+			removed.Routes[key] = route
+		case route.Schema == old.Schema:
+			withoutSchema := *route
+			withoutSchema.Schema = ""
+			updated.Routes[key] = &withoutSchema
+		default:
+			updated.Routes[key] = route
+		}
+	}
+	evicted := newUpdate(LIVE_ROUTES_EVICTED)
+	for key := range previous.RouteMap {
+		if _, exists := current.RouteMap[key]; !exists {
+			evicted.Keys = append(evicted.Keys, key)
+		}
+	}
+	for _, update := range []*LiveUpdate{added, updated, removed} {
+		if len(update.Routes) > 0 {
+			it.send(update)
+		}
+	}
+	if len(evicted.Keys) > 0 {
+		evicted.Routes = nil
+		it.send(evicted)
+	}
+	if previous.Error != current.Error || previous.UpdatingState != current.UpdatingState ||
+		previous.FailedUpdates != current.FailedUpdates || previous.Color != current.Color ||
+		previous.LastUpdated != current.LastUpdated {
+		update := newUpdate(LIVE_SERVICE_UPDATED)
+		update.Routes = nil
+		update.State = &Service{
+			Name:          current.Name,
+			Url:           current.Url,
+			LastUpdated:   current.LastUpdated,
+			Error:         current.Error,
+			Color:         current.Color,
+			UpdatingState: current.UpdatingState,
+			FailedUpdates: current.FailedUpdates}
+		it.send(update)
+	}
+}
@@ -1,21 +1,35 @@
 package model
 
 import (
+	"context"
 	"time"
 	"log"
 	"fmt"
+	"errors"
+	"sync"
+	"sync/atomic"
 )
 
 type Metric struct {
 	name    string
 	time    time.Time
 	value   interface{}
+	// short name and labels for consumers that do not use dotted names
+	series string
+	labels map[string]string
+	// time the value stays actual if it is not sent again, zero is forever
+	ttl time.Duration
 }
 
 type MetricConsumer interface {
 	consumeMetric(metric *Metric)
 }
 
+// Consumer that buffers metrics or holds connections, it is closed after it gets the last metric
+type metricCloser interface {
+	Close(ctx context.Context) error
+}
+
 type MetricConsumerStub struct {
 }
 
@@ -28,4 +42,131 @@ func (it *MetricConsumerStub) consumeMetric(metric *Metric) {
 
 func NewMetric(metricName string, value interface{}, t time.Time) *Metric {
 	return &Metric{name: metricName, value: value, time: t}
-}
\ No newline at end of file
+}
+
+const (
+	METRIC_SINK_GRAPHITE   = "graphite"
+	METRIC_SINK_PROMETHEUS = "prometheus"
+	METRIC_SINK_FILE       = "file"
+	METRIC_SINK_STATSD     = "statsd"
+
+	DefaultMetricSinkBufferSize = 1000
+	DefaultPrometheusPath       = "/metrics"
+	// route metrics stay actual for this many service update intervals
+	MetricTtlIntervals = 3
+)
+
+// Creates metric consumer described by sink config
+func NewMetricSink(config *MetricSinkConfig) (MetricConsumer, error) {
+	switch config.Type {
+	case METRIC_SINK_GRAPHITE:
+		if config.Url == "" {
+			return nil, errors.New("graphite sink url must not be empty")
+		}
+		return NewGraphite(config.Url, config.RepeatSendOnFail), nil
+	case METRIC_SINK_PROMETHEUS:
+		return NewPrometheus(), nil
+	case METRIC_SINK_FILE:
+		if config.File == "" {
+			return nil, errors.New("file sink file must not be empty")
+		}
+		return NewMetricFile(config.File)
+	case METRIC_SINK_STATSD:
+		if config.Url == "" {
+			return nil, errors.New("statsd sink url must not be empty")
+		}
+		return NewStatsd(config.Url)
+	default:
+		return nil, fmt.Errorf("unknown metric sink type %q", config.Type)
+	}
+}
+
+// Passes every metric to all sinks, each sink has own buffer and goroutine
+// so a slow or dead sink can not block the others
+type FanOutMetricConsumer struct {
+	sinks []*metricSink
+	// guards buffers of sinks which are closed on shutdown
+	mutex  sync.RWMutex
+	closed bool
+}
+
+type metricSink struct {
+	// first field to be 64-bit aligned for atomic operations
+	dropped  uint64
+	name     string
+	consumer MetricConsumer
+	metrics  chan *Metric
+	done     chan struct{}
+}
+
+func NewFanOutMetricConsumer() *FanOutMetricConsumer {
+	return &FanOutMetricConsumer{sinks: make([]*metricSink, 0)}
+}
+
+// Adds sink, must be called before metrics are consumed
+func (it *FanOutMetricConsumer) Add(name string, consumer MetricConsumer, bufferSize int) {
+	if bufferSize <= 0 {
+		bufferSize = DefaultMetricSinkBufferSize
+	}
+	sink := &metricSink{
+		name:     name,
+		consumer: consumer,
+		metrics:  make(chan *Metric, bufferSize),
+		done:     make(chan struct{})}
+	it.sinks = append(it.sinks, sink)
+	go sink.run()
+}
+
+func (it *FanOutMetricConsumer) Len() int {
+	return len(it.sinks)
+}
+
+func (it *FanOutMetricConsumer) consumeMetric(metric *Metric) {
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	if it.closed {
+		return
+	}
+	for _, sink := range it.sinks {
+		select {
+		case sink.metrics <- metric:
+		default:
+			dropped := atomic.AddUint64(&sink.dropped, 1)
+			if dropped%DefaultMetricSinkBufferSize == 1 {
+				log.Printf("error: metric buffer of %s sink is full, %v metrics are dropped so far", sink.name, dropped)
+			}
+		}
+	}
+}
+
+// Passes buffered metrics to sinks and closes them, metrics that come later are dropped
+func (it *FanOutMetricConsumer) Close(ctx context.Context) error {
+	it.mutex.Lock()
+	if !it.closed {
+		it.closed = true
+		for _, sink := range it.sinks {
+			close(sink.metrics)
+		}
+	}
+	it.mutex.Unlock()
+	for _, sink := range it.sinks {
+		select {
+		case <-sink.done:
+		case <-ctx.Done():
+			return fmt.Errorf("%s sink is not flushed: %s", sink.name, ctx.Err())
+		}
+		if closer, ok := sink.consumer.(metricCloser); ok {
+			if err := closer.Close(ctx); err != nil {
+				return fmt.Errorf("%s sink: %s", sink.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (sink *metricSink) run() {
+	defer close(sink.done)
+	for metric := range sink.metrics {
+		sink.consumer.consumeMetric(metric)
+	}
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Appends metrics to file in graphite plaintext format
+type MetricFile struct {
+	file *os.File
+}
+
+func NewMetricFile(fileName string) (*MetricFile, error) {
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &MetricFile{file: file}, nil
+}
+
+func (it *MetricFile) Close(ctx context.Context) error {
+	return it.file.Close()
+}
+
+func (it *MetricFile) consumeMetric(metric *Metric) {
+	message := fmt.Sprintf("%s %v %v\n", metric.name, metric.value, metric.time.Unix())
+	if _, err := it.file.WriteString(message); err != nil {
+		log.Printf("error: could not write metric to %s: %s", it.file.Name(), err)
+	}
+}
@@ -0,0 +1,334 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	NOTIFICATION_ALERT             = "alert"
+	NOTIFICATION_ROUTE_STATE       = "route_state"
+	NOTIFICATION_SERVICE_ERROR     = "service_error"
+	NOTIFICATION_SERVICE_RECOVERED = "service_recovered"
+
+	NOTIFICATION_CHANNEL_WEBHOOK = "webhook"
+	NOTIFICATION_CHANNEL_SLACK   = "slack"
+	NOTIFICATION_CHANNEL_EMAIL   = "email"
+
+	NotificationChannelBufferSize = 100
+)
+
+type Notification struct {
+	Type        string   `json:"type"`
+	Environment string   `json:"environment"`
+	Service     string   `json:"service"`
+	Context     string   `json:"context,omitempty"`
+	Route       string   `json:"route,omitempty"`
+	Severity    string   `json:"severity,omitempty"`
+	Title       string   `json:"title"`
+	Message     string   `json:"message"`
+	Time        JsonTime `json:"time"`
+}
+
+// Delivers notification somewhere
+type NotificationChannel interface {
+	Send(notification *Notification) error
+}
+
+// Routes notifications to channels by environment and type, every channel has own queue and rate limit
+type Notifications struct {
+	channels []*notificationRoute
+	// guards queues which are closed on shutdown
+	mutex  sync.RWMutex
+	closed bool
+}
+
+type notificationRoute struct {
+	name          string
+	channel       NotificationChannel
+	environments  map[string]bool
+	types         map[string]bool
+	maxPerMinute  int
+	queue         chan *Notification
+	done          chan struct{}
+	windowStart   time.Time
+	windowCounter int
+}
+
+func NewNotifications(config *NotificationsConfig) (*Notifications, error) {
+	notifications := &Notifications{channels: make([]*notificationRoute, 0)}
+	for _, channelConfig := range config.Channels {
+		channel, err := NewNotificationChannel(channelConfig)
+		if err != nil {
+			return nil, fmt.Errorf("notification channel %s: %s", channelConfig.Name, err)
+		}
+		route := &notificationRoute{
+			name:         channelConfig.Name,
+			channel:      channel,
+			environments: toSet(channelConfig.Environments),
+			types:        toSet(channelConfig.Events),
+			maxPerMinute: channelConfig.MaxPerMinute,
+			queue:        make(chan *Notification, NotificationChannelBufferSize),
+			done:         make(chan struct{})}
+		notifications.channels = append(notifications.channels, route)
+		go route.run()
+	}
+	return notifications, nil
+}
+
+// Creates channel described by config
+func NewNotificationChannel(config *NotificationChannelConfig) (NotificationChannel, error) {
+	switch config.Type {
+	case NOTIFICATION_CHANNEL_WEBHOOK:
+		return NewWebhookChannel(config.Url, config.Template, config.Headers)
+	case NOTIFICATION_CHANNEL_SLACK:
+		return NewSlackChannel(config.Url)
+	case NOTIFICATION_CHANNEL_EMAIL:
+		return NewEmailChannel(config.Smtp, config.To)
+	default:
+		return nil, fmt.Errorf("unknown notification channel type %q", config.Type)
+	}
+}
+
+// Passes notification to channels that accept it, never blocks
+func (notifications *Notifications) Notify(notification *Notification) {
+	if notifications == nil {
+		return
+	}
+	notifications.mutex.RLock()
+	defer notifications.mutex.RUnlock()
+	if notifications.closed {
+		return
+	}
+	for _, route := range notifications.channels {
+		if !route.accepts(notification) {
+			continue
+		}
+		select {
+		case route.queue <- notification:
+		default:
+			log.Printf("error: notification queue of %s is full, %s notification is dropped", route.name,
+				notification.Type)
+		}
+	}
+}
+
+func (notifications *Notifications) NotifyAlert(alert *Alert) {
+	notifications.Notify(&Notification{
+		Type:        NOTIFICATION_ALERT,
+		Environment: alert.Environment,
+		Service:     alert.Service,
+		Context:     alert.Context,
+		Route:       alert.Route,
+		Severity:    alert.Severity,
+		Title:       fmt.Sprintf("Alert %s is %s", alert.Rule, alert.Status),
+		Message:     alert.Message,
+		Time:        JsonTime(time.Now())})
+}
+
+func (route *notificationRoute) accepts(notification *Notification) bool {
+	if len(route.environments) > 0 && !route.environments[notification.Environment] {
+		return false
+	}
+	if len(route.types) > 0 && !route.types[notification.Type] {
+		return false
+	}
+	return true
+}
+
+// Sends queued notifications, later ones are dropped
+func (notifications *Notifications) Close(ctx context.Context) error {
+	notifications.mutex.Lock()
+	if !notifications.closed {
+		notifications.closed = true
+		for _, route := range notifications.channels {
+			close(route.queue)
+		}
+	}
+	notifications.mutex.Unlock()
+	for _, route := range notifications.channels {
+		select {
+		case <-route.done:
+		case <-ctx.Done():
+			return fmt.Errorf("queue of %s is not sent: %s", route.name, ctx.Err())
+		}
+	}
+	return nil
+}
+
+func (route *notificationRoute) run() {
+	defer close(route.done)
+	for notification := range route.queue {
+		if !route.allow(time.Now()) {
+			log.Printf("error: rate limit of %s is exceeded, %s notification is dropped", route.name, notification.Type)
+			continue
+		}
+		if err := route.channel.Send(notification); err != nil {
+			log.Printf("error: could not send notification to %s: %s", route.name, err)
+		}
+	}
+}
+
+// Fixed one minute window rate limit
+func (route *notificationRoute) allow(t time.Time) bool {
+	if route.maxPerMinute <= 0 {
+		return true
+	}
+	if t.Sub(route.windowStart) >= time.Minute {
+		route.windowStart = t
+		route.windowCounter = 0
+	}
+	if route.windowCounter >= route.maxPerMinute {
+		return false
+	}
+	route.windowCounter++
+	return true
+}
+
+// Posts notification to url, body is rendered by template or notification is sent as is
+type WebhookChannel struct {
+	url      string
+	template *template.Template
+	headers  map[string]string
+	client   *http.Client
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		js, err := json.Marshal(v)
+		return string(js), err
+	},
+}
+
+func NewWebhookChannel(url string, bodyTemplate string, headers map[string]string) (*WebhookChannel, error) {
+	if url == "" {
+		return nil, errors.New("webhook url must not be empty")
+	}
+	channel := &WebhookChannel{url: url, headers: headers, client: &http.Client{Timeout: 30 * time.Second}}
+	if bodyTemplate != "" {
+		parsed, err := template.New("webhook").Funcs(webhookTemplateFuncs).Parse(bodyTemplate)
+		if err != nil {
+			return nil, err
+		}
+		channel.template = parsed
+	}
+	return channel, nil
+}
+
+func (channel *WebhookChannel) Send(notification *Notification) error {
+	body := &bytes.Buffer{}
+	if channel.template != nil {
+		if err := channel.template.Execute(body, notification); err != nil {
+			return err
+		}
+	} else if err := json.NewEncoder(body).Encode(notification); err != nil {
+		return err
+	}
+	return postJson(channel.client, channel.url, body.Bytes(), channel.headers)
+}
+
+// Posts notification to Slack compatible incoming webhook
+type SlackChannel struct {
+	url    string
+	client *http.Client
+}
+
+func NewSlackChannel(url string) (*SlackChannel, error) {
+	if url == "" {
+		return nil, errors.New("slack url must not be empty")
+	}
+	return &SlackChannel{url: url, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (channel *SlackChannel) Send(notification *Notification) error {
+	text := fmt.Sprintf("*[%s] %s*\n%s", notificationTarget(notification), notification.Title, notification.Message)
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	return postJson(channel.client, channel.url, body, nil)
+}
+
+// Sends notification by email
+type EmailChannel struct {
+	config *SmtpConfig
+	to     []string
+}
+
+func NewEmailChannel(config *SmtpConfig, to []string) (*EmailChannel, error) {
+	if config == nil || config.Host == "" {
+		return nil, errors.New("smtp host must not be empty")
+	}
+	if config.From == "" {
+		return nil, errors.New("smtp from must not be empty")
+	}
+	if len(to) == 0 {
+		return nil, errors.New("email recipients must not be empty")
+	}
+	return &EmailChannel{config: config, to: to}, nil
+}
+
+func (channel *EmailChannel) Send(notification *Notification) error {
+	port := channel.config.Port
+	if port == 0 {
+		port = 25
+	}
+	address := net.JoinHostPort(channel.config.Host, strconv.Itoa(port))
+	var auth smtp.Auth
+	if channel.config.Login != "" {
+		auth = smtp.PlainAuth("", channel.config.Login, channel.config.Pass, channel.config.Host)
+	}
+	subject := fmt.Sprintf("[camel-graph] [%s] %s", notificationTarget(notification), notification.Title)
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		channel.config.From, strings.Join(channel.to, ", "), subject, notification.Message)
+	return smtp.SendMail(address, auth, channel.config.From, channel.to, []byte(message))
+}
+
+func postJson(client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("Status " + resp.Status)
+	}
+	return nil
+}
+
+// env:service:route
+func notificationTarget(notification *Notification) string {
+	target := notification.Environment + ":" + notification.Service
+	if notification.Route != "" {
+		target += ":" + notification.Route
+	}
+	return target
+}
+
+func toSet(list []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, v := range list {
+		set[v] = true
+	}
+	return set
+}
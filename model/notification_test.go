@@ -0,0 +1,225 @@
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testNotification() *Notification {
+	return &Notification{
+		Type:        NOTIFICATION_ROUTE_STATE,
+		Environment: "dev",
+		Service:     "smx",
+		Route:       "myRoute",
+		Title:       "Route state is changed",
+		Message:     "Route myRoute state is changed from Started to Stopped",
+		Time:        JsonTime(time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC))}
+}
+
+// Records requests of webhook and slack channels
+func startHookServer(t *testing.T) (*httptest.Server, chan *http.Request, chan string) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("could not read body: %s", err)
+		}
+		requests <- r
+		bodies <- string(body)
+	}))
+	return server, requests, bodies
+}
+
+func TestWebhookChannelSendsNotificationAsJson(t *testing.T) {
+	server, requests, bodies := startHookServer(t)
+	defer server.Close()
+	channel, err := NewWebhookChannel(server.URL, "", map[string]string{"X-Token": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := channel.Send(testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	request := <-requests
+	if request.Header.Get("X-Token") != "secret" {
+		t.Errorf("header X-Token = %q, want secret", request.Header.Get("X-Token"))
+	}
+	if request.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("content type = %q", request.Header.Get("Content-Type"))
+	}
+	received := &Notification{}
+	if err := json.Unmarshal([]byte(<-bodies), received); err != nil {
+		t.Fatal(err)
+	}
+	if received.Route != "myRoute" || received.Type != NOTIFICATION_ROUTE_STATE || received.Environment != "dev" {
+		t.Errorf("unexpected notification %+v", received)
+	}
+}
+
+func TestWebhookChannelRendersTemplate(t *testing.T) {
+	server, _, bodies := startHookServer(t)
+	defer server.Close()
+	channel, err := NewWebhookChannel(server.URL, `{"summary": {{json .Title}}, "env": "{{.Environment}}"}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := channel.Send(testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"summary": "Route state is changed", "env": "dev"}`
+	if body := <-bodies; body != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+func TestWebhookChannelReportsFailedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	channel, _ := NewWebhookChannel(server.URL, "", nil)
+	if err := channel.Send(testNotification()); err == nil {
+		t.Error("error is expected on 502")
+	}
+}
+
+func TestSlackChannelSendsText(t *testing.T) {
+	server, _, bodies := startHookServer(t)
+	defer server.Close()
+	channel, err := NewSlackChannel(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := channel.Send(testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	message := map[string]string{}
+	if err := json.Unmarshal([]byte(<-bodies), &message); err != nil {
+		t.Fatal(err)
+	}
+	want := "*[dev:smx:myRoute] Route state is changed*\nRoute myRoute state is changed from Started to Stopped"
+	if message["text"] != want {
+		t.Errorf("text = %q, want %q", message["text"], want)
+	}
+}
+
+// Minimal SMTP server that accepts one message and passes its data
+func startSmtpServer(t *testing.T) (string, int, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := make(chan string, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) {
+			conn.Write([]byte(line + "\r\n"))
+		}
+		reply("220 localhost ESMTP")
+		data := &strings.Builder{}
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "DATA"):
+				reply("354 go ahead")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				messages <- data.String()
+				reply("250 accepted")
+			case strings.HasPrefix(command, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	address := listener.Addr().(*net.TCPAddr)
+	return address.IP.String(), address.Port, messages
+}
+
+func TestEmailChannelSendsMessage(t *testing.T) {
+	host, port, messages := startSmtpServer(t)
+	channel, err := NewEmailChannel(&SmtpConfig{Host: host, Port: port, From: "graph@example.com"},
+		[]string{"ops@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := channel.Send(testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-messages:
+		for _, want := range []string{
+			"From: graph@example.com",
+			"To: ops@example.com",
+			"Subject: [camel-graph] [dev:smx:myRoute] Route state is changed",
+			"Route myRoute state is changed from Started to Stopped"} {
+			if !strings.Contains(message, want) {
+				t.Errorf("message does not contain %q:\n%s", want, message)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message is not received on port " + strconv.Itoa(port))
+	}
+}
+
+func TestNotificationRouteAllowsMaxPerMinute(t *testing.T) {
+	route := &notificationRoute{maxPerMinute: 2}
+	start := time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC)
+	for i, test := range []struct {
+		at    time.Duration
+		allow bool
+	}{
+		{0, true},
+		{10 * time.Second, true},
+		{20 * time.Second, false},
+		{59 * time.Second, false},
+		{60 * time.Second, true},
+		{61 * time.Second, true},
+		{62 * time.Second, false},
+	} {
+		if allowed := route.allow(start.Add(test.at)); allowed != test.allow {
+			t.Errorf("#%v at %v: allow = %v, want %v", i, test.at, allowed, test.allow)
+		}
+	}
+}
+
+func TestNotificationRouteWithoutLimitAllowsAll(t *testing.T) {
+	route := &notificationRoute{}
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		if !route.allow(now) {
+			t.Fatalf("notification %v is not allowed", i)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	DefaultUpdateIntervalSeconds = 60
+	DefaultTimeoutSeconds        = 60
+	DefaultRetryAttempts         = 1
+	DefaultRetryBackoffMillis    = 1000
+	DefaultRetryMaxBackoffMillis = 30000
+)
+
+// Polling settings of a service resolved from service, environment and instance configs
+type pollingPolicy struct {
+	serviceUpdateInterval time.Duration
+	routeUpdateInterval   time.Duration
+	timeout               time.Duration
+	retryAttempts         int
+	retryBackoff          time.Duration
+	retryMaxBackoff       time.Duration
+	routeWorkers          int
+	routeBatchSize        int
+	routeGracePeriod      time.Duration
+}
+
+// The first positive value of service, environment and instance configs wins
+func resolvePollingPolicy(configs ...PollingConfig) *pollingPolicy {
+	pick := func(defaultValue int, get func(config PollingConfig) int) int {
+		for _, config := range configs {
+			if value := get(config); value > 0 {
+				return value
+			}
+		}
+		return defaultValue
+	}
+	pickRetry := func(defaultValue int, get func(retry *RetryConfig) int) int {
+		return pick(defaultValue, func(config PollingConfig) int {
+			if config.Retry == nil {
+				return 0
+			}
+			return get(config.Retry)
+		})
+	}
+	return &pollingPolicy{
+		serviceUpdateInterval: seconds(pick(DefaultUpdateIntervalSeconds, func(config PollingConfig) int {
+			return config.ServiceUpdateIntervalSeconds
+		})),
+		routeUpdateInterval: seconds(pick(DefaultUpdateIntervalSeconds, func(config PollingConfig) int {
+			return config.RouteUpdateIntervalSeconds
+		})),
+		timeout: seconds(pick(DefaultTimeoutSeconds, func(config PollingConfig) int {
+			return config.TimeoutSeconds
+		})),
+		retryAttempts: pickRetry(DefaultRetryAttempts, func(retry *RetryConfig) int {
+			return retry.Attempts
+		}),
+		retryBackoff: millis(pickRetry(DefaultRetryBackoffMillis, func(retry *RetryConfig) int {
+			return retry.BackoffMillis
+		})),
+		retryMaxBackoff: millis(pickRetry(DefaultRetryMaxBackoffMillis, func(retry *RetryConfig) int {
+			return retry.MaxBackoffMillis
+		})),
+		routeWorkers: pick(DefaultRouteWorkers, func(config PollingConfig) int {
+			return config.RouteWorkers
+		}),
+		routeBatchSize: pick(DefaultRouteBatchSize, func(config PollingConfig) int {
+			return config.RouteBatchSize
+		}),
+		routeGracePeriod: seconds(pick(DefaultRouteGracePeriodSeconds, func(config PollingConfig) int {
+			return config.RouteGracePeriodSeconds
+		}))}
+}
+
+// Calls endpoint retrying failed attempts with doubling backoff until ctx is canceled
+func (policy *pollingPolicy) retry(ctx context.Context, call func() ([]byte, error)) (body []byte, err error) {
+	backoff := policy.retryBackoff
+	for attempt := 1; ; attempt++ {
+		body, err = call()
+		if err == nil || attempt >= policy.retryAttempts {
+			return body, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > policy.retryMaxBackoff {
+			backoff = policy.retryMaxBackoff
+		}
+	}
+}
+
+func seconds(value int) time.Duration {
+	return time.Duration(value) * time.Second
+}
+
+func millis(value int) time.Duration {
+	return time.Duration(value) * time.Millisecond
+}
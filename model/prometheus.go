@@ -0,0 +1,134 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PrometheusNamespace   = "camel_graph"
+	PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// Cumulative route counters, everything else is exposed as gauge
+var prometheusCounters = map[string]bool{
+	"exchanges_total":     true,
+	"exchanges_completed": true,
+	"exchanges_failed":    true,
+	"failures_handled":    true,
+	"redeliveries":        true,
+}
+
+// Keeps last value of every labeled metric and exposes them in Prometheus text format.
+// Values that are not refreshed within their ttl are dropped, so removed routes and stopped services disappear.
+type Prometheus struct {
+	mutex   sync.RWMutex
+	samples map[string]*prometheusSample
+}
+
+type prometheusSample struct {
+	// environment label, samples are shown to users who can see environment
+	environment string
+	series string
+	labels string
+	value  interface{}
+	// zero if sample does not expire
+	expires time.Time
+}
+
+func NewPrometheus() *Prometheus {
+	return &Prometheus{samples: make(map[string]*prometheusSample)}
+}
+
+func (prometheus *Prometheus) consumeMetric(metric *Metric) {
+	if metric.series == "" {
+		return
+	}
+	labels := formatPrometheusLabels(metric.labels)
+	prometheus.mutex.Lock()
+	defer prometheus.mutex.Unlock()
+	sample := &prometheusSample{
+		environment: metric.labels["environment"],
+		series:      metric.series,
+		labels:      labels,
+		value:       metric.value}
+	if metric.ttl > 0 {
+		sample.expires = metric.time.Add(metric.ttl)
+	}
+	prometheus.samples[metric.series+labels] = sample
+}
+
+// Removes samples which are not refreshed in time
+func (prometheus *Prometheus) expire(now time.Time) {
+	prometheus.mutex.Lock()
+	defer prometheus.mutex.Unlock()
+	for key, sample := range prometheus.samples {
+		if !sample.expires.IsZero() && sample.expires.Before(now) {
+			delete(prometheus.samples, key)
+		}
+	}
+}
+
+func (prometheus *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prometheus.expire(time.Now())
+	principal := PrincipalFromRequest(r)
+	prometheus.mutex.RLock()
+	samples := make([]*prometheusSample, 0, len(prometheus.samples))
+	for _, sample := range prometheus.samples {
+		if principal.CanSee(sample.environment) {
+			samples = append(samples, sample)
+		}
+	}
+	prometheus.mutex.RUnlock()
+
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].series != samples[j].series {
+			return samples[i].series < samples[j].series
+		}
+		return samples[i].labels < samples[j].labels
+	})
+	buf := &bytes.Buffer{}
+	series := ""
+	for _, sample := range samples {
+		name := PrometheusNamespace + "_" + sample.series
+		if sample.series != series {
+			series = sample.series
+			metricType := "gauge"
+			if prometheusCounters[series] {
+				metricType = "counter"
+			}
+			fmt.Fprintf(buf, "# TYPE %s %s\n", name, metricType)
+		}
+		fmt.Fprintf(buf, "%s%s %v\n", name, sample.labels, sample.value)
+	}
+	w.Header().Set("Content-Type", PrometheusContentType)
+	w.Write(buf.Bytes())
+}
+
+func formatPrometheusLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", name, escapePrometheusLabel(labels[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapePrometheusLabel(value string) string {
+	value = strings.Replace(value, "\\", "\\\\", -1)
+	value = strings.Replace(value, "\"", "\\\"", -1)
+	value = strings.Replace(value, "\n", "\\n", -1)
+	return value
+}
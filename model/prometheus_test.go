@@ -0,0 +1,55 @@
+package model
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func prometheusTestMetric(environment string, value int, t time.Time, ttl time.Duration) *Metric {
+	metric := NewMetric("camel-graph."+environment+".smx.route.exchanges_total", value, t)
+	metric.series = "exchanges_total"
+	metric.labels = map[string]string{"environment": environment, "service": "smx", "route": "myRoute"}
+	metric.ttl = ttl
+	return metric
+}
+
+func scrape(prometheus *Prometheus, principal *Principal) string {
+	request := httptest.NewRequest("GET", "/metrics", nil)
+	if principal != nil {
+		request = request.WithContext(context.WithValue(request.Context(), principalKey{}, principal))
+	}
+	recorder := httptest.NewRecorder()
+	prometheus.ServeHTTP(recorder, request)
+	body, _ := ioutil.ReadAll(recorder.Result().Body)
+	return string(body)
+}
+
+func TestPrometheusShowsEnvironmentsOfPrincipal(t *testing.T) {
+	prometheus := NewPrometheus()
+	prometheus.consumeMetric(prometheusTestMetric("dev", 1, time.Now(), 0))
+	prometheus.consumeMetric(prometheusTestMetric("prod", 2, time.Now(), 0))
+
+	all := scrape(prometheus, nil)
+	if !strings.Contains(all, `environment="dev"`) || !strings.Contains(all, `environment="prod"`) {
+		t.Errorf("every environment must be shown without access control:\n%s", all)
+	}
+	developer := &Principal{Login: "john", roles: []*RoleConfig{{Name: "developer", Environments: []string{"dev"}}}}
+	visible := scrape(prometheus, developer)
+	if !strings.Contains(visible, `environment="dev"`) || strings.Contains(visible, `environment="prod"`) {
+		t.Errorf("only dev must be shown:\n%s", visible)
+	}
+}
+
+func TestPrometheusExpiresSamples(t *testing.T) {
+	prometheus := NewPrometheus()
+	prometheus.consumeMetric(prometheusTestMetric("dev", 1, time.Now().Add(-time.Hour), time.Minute))
+	prometheus.consumeMetric(prometheusTestMetric("prod", 2, time.Now(), time.Minute))
+	body := scrape(prometheus, nil)
+	if strings.Contains(body, `environment="dev"`) || !strings.Contains(body, `environment="prod"`) {
+		t.Errorf("only sample that is refreshed in time must be shown:\n%s", body)
+	}
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+)
+
+const DefaultRedactionMask = "******"
+
+var (
+	// query parameters which names contain any of these are masked
+	DefaultRedactedParameters = []string{"password", "passwd", "passphrase", "secret", "token", "apikey", "accesskey",
+		"credential", "authorization"}
+	// xml attributes of route schemas that are masked entirely
+	DefaultRedactedAttributes = []string{"password"}
+
+	userinfoPattern  = regexp.MustCompile(`(://[^/?#@:\s"]*):[^/?#@\s"]*@`)
+	parameterPattern = regexp.MustCompile(`(^|&amp;|&)([^=&]+)=([^&]*)`)
+	attributePattern = regexp.MustCompile(`([\w:.-]+)="([^"]*)"`)
+)
+
+// Masks passwords of uri userinfo, password-like query parameters and configured schema attributes
+type redactor struct {
+	disabled   bool
+	mask       string
+	parameters []string
+	attributes []string
+}
+
+// Nil config means redaction with defaults
+func newRedactor(config *RedactionConfig) *redactor {
+	redactor := &redactor{
+		mask:       DefaultRedactionMask,
+		parameters: DefaultRedactedParameters,
+		attributes: DefaultRedactedAttributes}
+	if config == nil {
+		return redactor
+	}
+	redactor.disabled = config.Disabled
+	if config.Mask != "" {
+		redactor.mask = config.Mask
+	}
+	for _, parameter := range config.Parameters {
+		redactor.parameters = append(redactor.parameters, strings.ToLower(parameter))
+	}
+	redactor.attributes = append(redactor.attributes, config.Attributes...)
+	return redactor
+}
+
+// Redacts endpoint uri, query of uri may be xml escaped
+func (redactor *redactor) uri(uri string) string {
+	if redactor.disabled {
+		return uri
+	}
+	uri = userinfoPattern.ReplaceAllString(uri, "$1:"+redactor.mask+"@")
+	i := strings.Index(uri, "?")
+	if i < 0 {
+		return uri
+	}
+	query := parameterPattern.ReplaceAllStringFunc(uri[i+1:], func(match string) string {
+		groups := parameterPattern.FindStringSubmatch(match)
+		if redactor.isSecretParameter(groups[2]) {
+			return groups[1] + groups[2] + "=" + redactor.mask
+		}
+		return match
+	})
+	return uri[:i+1] + query
+}
+
+// Redacts every attribute of route schema, configured attributes are masked entirely, others as uris
+func (redactor *redactor) schema(schema string) string {
+	if redactor.disabled {
+		return schema
+	}
+	return attributePattern.ReplaceAllStringFunc(schema, func(match string) string {
+		groups := attributePattern.FindStringSubmatch(match)
+		for _, attribute := range redactor.attributes {
+			if strings.EqualFold(groups[1], attribute) {
+				return groups[1] + `="` + redactor.mask + `"`
+			}
+		}
+		return groups[1] + `="` + redactor.uri(groups[2]) + `"`
+	})
+}
+
+func (redactor *redactor) isSecretParameter(name string) bool {
+	name = strings.ToLower(name)
+	for _, parameter := range redactor.parameters {
+		if strings.Contains(name, parameter) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"time"
+)
+
+// Applies new configuration of environments and services: new ones start polling, removed ones are stopped,
+// services with changed url, transport or polling settings are restarted, all of them are restarted on change
+// of endpoint rules or redaction, others keep their routes and take new credentials and color.
+// All new services are created before anything is changed, so failed reload keeps previous state.
+func (instance *Instance) Reload(config *InstanceConfig) error {
+	if err := checkEnvironmentConfigs(config.Environments); err != nil {
+		return err
+	}
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+
+	// endpoints and schemas that are already collected are normalized and masked by previous rules
+	rulesChanged := !reflect.DeepEqual(instance.config.EndpointRules, config.EndpointRules) ||
+		!reflect.DeepEqual(instance.config.Redaction, config.Redaction)
+	created := make([]*Service, 0)
+	settings := make(map[*Service]*serviceSettings)
+	serviceMaps := make(map[*Environment]map[string]*Service)
+	environments := make([]*Environment, 0, len(config.Environments))
+	// changes are logged when reload succeeds
+	changes := make([]string, 0)
+	fail := func(err error) error {
+		for _, service := range created {
+			service.cancel()
+		}
+		return err
+	}
+	for _, envConfig := range config.Environments {
+		var current *Environment
+		for _, environment := range instance.Environments {
+			if environment.Name == envConfig.Name {
+				current = environment
+				break
+			}
+		}
+		if current == nil {
+			environment, err := newEnvironment(instance.ctx, config, envConfig, instance.metricConsumer,
+				instance.history, instance.notifications, instance.topology, instance.updates)
+			if err != nil {
+				return fail(err)
+			}
+			for _, service := range environment.ServiceMap {
+				created = append(created, service)
+			}
+			changes = append(changes, fmt.Sprintf("%s environment is added", environment.Name))
+			environments = append(environments, environment)
+			continue
+		}
+		serviceMap := make(map[string]*Service)
+		for _, serviceConfig := range envConfig.Services {
+			service, exists := current.ServiceMap[serviceConfig.Name]
+			policy := resolvePollingPolicy(serviceConfig.PollingConfig, envConfig.PollingConfig, config.PollingConfig)
+			if exists && !rulesChanged && service.Url == serviceConfig.Url && *service.policy == *policy &&
+				reflect.DeepEqual(service.transport, serviceConfig.Transport) {
+				auth, err := newAuthenticator(serviceConfig.Authorization, service.client)
+				if err != nil {
+					return fail(fmt.Errorf("service %s authorization: %s", serviceConfig.Name, err))
+				}
+				settings[service] = &serviceSettings{
+					config: serviceConfig,
+					auth:   auth}
+				serviceMap[service.Name] = service
+				continue
+			}
+			service, err := newService(instance.ctx, config, envConfig, serviceConfig, current,
+				instance.metricConsumer, instance.history, instance.notifications, instance.topology, instance.updates)
+			if err != nil {
+				return fail(err)
+			}
+			created = append(created, service)
+			serviceMap[service.Name] = service
+			if exists {
+				changes = append(changes, fmt.Sprintf("%s:%s service connection, polling, endpoint rules or redaction are changed, "+
+					"it is restarted", current.Name, service.Name))
+			} else {
+				changes = append(changes, fmt.Sprintf("%s:%s service is added", current.Name, service.Name))
+			}
+		}
+		serviceMaps[current] = serviceMap
+		environments = append(environments, current)
+	}
+
+	// everything is created, previous services that are replaced or removed are stopped
+	stopped := make([]*Service, 0)
+	for _, environment := range instance.Environments {
+		serviceMap, kept := serviceMaps[environment]
+		for name, service := range environment.ServiceMap {
+			if kept && serviceMap[name] == service {
+				continue
+			}
+			service.Stop()
+			stopped = append(stopped, service)
+		}
+	}
+	// stopped pollers must not record topology after their routes are removed from it
+	for _, service := range stopped {
+		service.done.Wait()
+	}
+	for _, environment := range instance.Environments {
+		serviceMap, kept := serviceMaps[environment]
+		if !kept {
+			for name := range environment.ServiceMap {
+				instance.removeFromTopology(environment.Name, name)
+			}
+			log.Printf("info:  %s environment is removed", environment.Name)
+			continue
+		}
+		for name := range environment.ServiceMap {
+			if _, exists := serviceMap[name]; !exists {
+				instance.removeFromTopology(environment.Name, name)
+				log.Printf("info:  %s:%s service is removed", environment.Name, name)
+			}
+		}
+		// map is replaced so readers of previous one are not affected
+		environment.ServiceMap = serviceMap
+	}
+	for service, serviceSettings := range settings {
+		service.reconfigure(serviceSettings)
+	}
+	for _, service := range created {
+		service.start()
+	}
+	instance.config = config
+	instance.Environments = environments
+	for _, change := range changes {
+		log.Printf("info:  %s", change)
+	}
+	return nil
+}
+
+// Checks configs before anything is changed so reload is applied completely or not at all
+func checkEnvironmentConfigs(configs []*EnvironmentConfig) error {
+	environmentNames := make(map[string]bool)
+	for _, envConfig := range configs {
+		if envConfig.Name == "" {
+			return errors.New("environment name must not be empty")
+		}
+		if environmentNames[envConfig.Name] {
+			return fmt.Errorf("environment %s is duplicated", envConfig.Name)
+		}
+		environmentNames[envConfig.Name] = true
+		serviceNames := make(map[string]bool)
+		for _, serviceConfig := range envConfig.Services {
+			if serviceConfig.Name == "" {
+				return errors.New("service name must not be empty")
+			}
+			if serviceConfig.Url == "" {
+				return errors.New("service url must not be empty")
+			}
+			if serviceNames[serviceConfig.Name] {
+				return fmt.Errorf("service %s of %s environment is duplicated", serviceConfig.Name, envConfig.Name)
+			}
+			serviceNames[serviceConfig.Name] = true
+		}
+	}
+	return nil
+}
+
+// Routes of removed service are removed from topology
+func (instance *Instance) removeFromTopology(environment string, service string) {
+	if instance.topology != nil {
+		instance.topology.observe(environment, service, nil, time.Now())
+	}
+}
+
+func containsEnvironment(list []*Environment, entry *Environment) bool {
+	for _, v := range list {
+		if v == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// Calls onChange when modification time of file changes until ctx is done
+func WatchConfig(ctx context.Context, fileName string, interval time.Duration, onChange func()) {
+	lastModified := time.Time{}
+	if info, err := os.Stat(fileName); err == nil {
+		lastModified = info.ModTime()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(fileName)
+		if err != nil {
+			log.Printf("error: could not check %s: %s", fileName, err)
+			continue
+		}
+		if !info.ModTime().Equal(lastModified) {
+			lastModified = info.ModTime()
+			onChange()
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Jolokia stand-in without routes
+func startEmptyJolokia(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&ReadRouteResponse{Status: 200, Value: map[string]ReadRouteEntry{}})
+	}))
+}
+
+func reloadTestConfig(services ...*ServiceConfig) *InstanceConfig {
+	return &InstanceConfig{Environments: []*EnvironmentConfig{{Name: "dev", Services: services}}}
+}
+
+func TestFailedReloadKeepsPreviousState(t *testing.T) {
+	server := startEmptyJolokia(t)
+	defer server.Close()
+	var consumer MetricConsumer = &MetricConsumerStub{}
+	config := reloadTestConfig(&ServiceConfig{Name: "a", Url: server.URL})
+	instance, err := NewInstance(config, &consumer, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer instance.Shutdown(context.Background())
+	before := instance.Environments[0].ServiceMap["a"]
+
+	// a is restarted because of url, b is new, c fails on missing CA file
+	err = instance.Reload(reloadTestConfig(
+		&ServiceConfig{Name: "a", Url: server.URL + "/"},
+		&ServiceConfig{Name: "b", Url: server.URL},
+		&ServiceConfig{Name: "c", Url: server.URL, Transport: &TransportConfig{CaFile: "missing-ca.pem"}}))
+	if err == nil {
+		t.Fatal("reload with missing CA file must fail")
+	}
+	if instance.config != config {
+		t.Error("config is replaced by failed reload")
+	}
+	serviceMap := instance.Environments[0].ServiceMap
+	if len(serviceMap) != 1 || serviceMap["a"] != before {
+		t.Errorf("service map is changed by failed reload: %v", serviceMap)
+	}
+	if before.ctx.Err() != nil {
+		t.Error("service is stopped by failed reload")
+	}
+}
+
+func TestReloadStopsReplacedServices(t *testing.T) {
+	server := startEmptyJolokia(t)
+	defer server.Close()
+	var consumer MetricConsumer = &MetricConsumerStub{}
+	instance, err := NewInstance(reloadTestConfig(
+		&ServiceConfig{Name: "a", Url: server.URL},
+		&ServiceConfig{Name: "b", Url: server.URL},
+		&ServiceConfig{Name: "c", Url: server.URL}), &consumer, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer instance.Shutdown(context.Background())
+	previous := instance.Environments[0].ServiceMap
+
+	config := reloadTestConfig(
+		&ServiceConfig{Name: "a", Url: server.URL, Color: "#fff"},
+		&ServiceConfig{Name: "b", Url: server.URL + "/"},
+		&ServiceConfig{Name: "d", Url: server.URL})
+	if err := instance.Reload(config); err != nil {
+		t.Fatal(err)
+	}
+	serviceMap := instance.Environments[0].ServiceMap
+	if serviceMap["a"] != previous["a"] || previous["a"].ctx.Err() != nil {
+		t.Error("service a must be kept running")
+	}
+	if serviceMap["b"] == previous["b"] || previous["b"].ctx.Err() == nil {
+		t.Error("service b must be restarted")
+	}
+	if _, exists := serviceMap["c"]; exists || previous["c"].ctx.Err() == nil {
+		t.Error("service c must be stopped")
+	}
+	if serviceMap["d"] == nil || serviceMap["d"].ctx.Err() != nil {
+		t.Error("service d must be started")
+	}
+	if instance.config != config {
+		t.Error("config is not replaced")
+	}
+}
+
+func TestReloadRestartsServicesOnRedactionChange(t *testing.T) {
+	server := startEmptyJolokia(t)
+	defer server.Close()
+	var consumer MetricConsumer = &MetricConsumerStub{}
+	instance, err := NewInstance(reloadTestConfig(&ServiceConfig{Name: "a", Url: server.URL}), &consumer,
+		nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer instance.Shutdown(context.Background())
+	previous := instance.Environments[0].ServiceMap["a"]
+
+	config := reloadTestConfig(&ServiceConfig{Name: "a", Url: server.URL})
+	config.Redaction = &RedactionConfig{Parameters: []string{"sasKey"}}
+	if err := instance.Reload(config); err != nil {
+		t.Fatal(err)
+	}
+	service := instance.Environments[0].ServiceMap["a"]
+	if service == previous || previous.ctx.Err() == nil {
+		t.Error("service must be restarted so collected uris are masked again")
+	}
+	if service.redactor.uri("amqp:queue:a?sasKey=key") == "amqp:queue:a?sasKey=key" {
+		t.Error("restarted service does not use new redaction")
+	}
+}
@@ -7,6 +7,9 @@ type Route struct {
 	Name        string     `json:"name,omitempty"`
 	Error       string     `json:"error,omitempty"`
 	LastUpdated JsonTime   `json:"lastUpdated"`
+	FirstSeen   JsonTime   `json:"firstSeen"`
+	// set while route is absent in service, route is evicted after grace period
+	RemovedAt   *JsonTime  `json:"removedAt,omitempty"`
 	State       string     `json:"state,omitempty"`
 	Uptime      string     `json:"uptime,omitempty"`
 	Schema      string     `json:"schema,omitempty"`
@@ -25,13 +28,31 @@ type Route struct {
 	FailuresHandled     int `json:"failuresHandled,omitempty"`
 	Redeliveries        int `json:"redeliveries,omitempty"`
 	StartTimestamp      string`json:"startTimestamp,omitempty"`
+	// changes since previous poll
+	RatesComputed        bool    `json:"ratesComputed,omitempty"`
+	ExchangesDelta       int     `json:"exchangesDelta,omitempty"`
+	ExchangesFailedDelta int     `json:"exchangesFailedDelta,omitempty"`
+	ExchangesPerSecond   float64 `json:"exchangesPerSecond,omitempty"`
+	FailuresPerSecond    float64 `json:"failuresPerSecond,omitempty"`
+	FailureRatio         float64 `json:"failureRatio,omitempty"`
 	// meta
 
 	// DONE, FAILED
 	UpdatingState string `json:"updatingState,omitempty"`
-	metrics chan *Metric
 	service *Service
-	upd     chan time.Time
+	lastSample *routeSample
+	// time of the next endpoints and schema update
+	nextUpdate time.Time
+	// topology of the last successful endpoints and schema update
+	topology *TopologyRoute
+}
+
+// counters of previous poll
+type routeSample struct {
+	time            time.Time
+	startTimestamp  string
+	exchangesTotal  int
+	exchangesFailed int
 }
 
 type Context struct {
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRouteWorkers   = 4
+	DefaultRouteBatchSize = 100
+
+	// route schedule is checked this many times per route update interval
+	RouteSchedulerSlots = 10
+	// intervals are randomly changed by this share so services and routes do not poll in step
+	PollingJitter = 0.1
+)
+
+// Routes of one jolokia bulk request
+type routeBatch struct {
+	routes    []*Route
+	responses []*ReadResponse
+	err       error
+}
+
+// Interval changed randomly by up to PollingJitter of it
+func jitter(interval time.Duration) time.Duration {
+	spread := int64(float64(interval) * PollingJitter)
+	if spread <= 0 {
+		return interval
+	}
+	return interval - time.Duration(spread) + time.Duration(rand.Int63n(2*spread+1))
+}
+
+// Period of route schedule checks
+func (policy *pollingPolicy) routeSchedulerPeriod() time.Duration {
+	period := policy.routeUpdateInterval / RouteSchedulerSlots
+	if period < time.Second {
+		period = time.Second
+	}
+	return period
+}
+
+// Schedules the next update of route, the first one is placed anywhere within interval
+// so routes that are found together are not updated together
+func (service *Service) scheduleRoute(route *Route, t time.Time, first bool) {
+	interval := service.policy.routeUpdateInterval
+	if first {
+		route.nextUpdate = t.Add(time.Duration(rand.Int63n(int64(interval)) + 1))
+	} else {
+		route.nextUpdate = t.Add(jitter(interval))
+	}
+}
+
+// Returns routes which are still present in service and are due to update
+func (service *Service) dueRoutes(t time.Time) []*Route {
+	routes := make([]*Route, 0)
+	for _, route := range service.RouteMap {
+		if route.State != NONE && !route.nextUpdate.After(t) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// Requests endpoints and schemas of routes in batches, batches are requested by a bounded number of workers.
+// Routes are not changed here so polling goroutine waits for the result and applies it.
+func (service *Service) fetchRoutes(routes []*Route) []*routeBatch {
+	batches := make([]*routeBatch, 0, len(routes)/service.policy.routeBatchSize+1)
+	for start := 0; start < len(routes); start += service.policy.routeBatchSize {
+		end := start + service.policy.routeBatchSize
+		if end > len(routes) {
+			end = len(routes)
+		}
+		batches = append(batches, &routeBatch{routes: routes[start:end]})
+	}
+	workers := service.policy.routeWorkers
+	if workers > len(batches) {
+		workers = len(batches)
+	}
+	queue := make(chan *routeBatch)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range queue {
+				batch.fetch(service)
+			}
+		}()
+	}
+	for _, batch := range batches {
+		queue <- batch
+	}
+	close(queue)
+	wg.Wait()
+	return batches
+}
+
+func (batch *routeBatch) fetch(service *Service) {
+	requests := make([]*JolokiaRequest, 0, 2*len(batch.routes))
+	for _, route := range batch.routes {
+		mbean := fmt.Sprintf(RouteMBean, route.Context, route.Name)
+		requests = append(requests,
+			NewJolokiaExec(mbean, RouteEndpointsOperation, true),
+			NewJolokiaExec(mbean, RouteSchemaOperation, true))
+	}
+	batch.responses, batch.err = service.bulk(requests)
+	if batch.err != nil {
+		log.Printf("error: %s:%s error during getting %v routes from %s: %s", service.environment.Name,
+			service.Name, len(batch.routes), service.config.Url, batch.err)
+	}
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// One poll of service with 5000 routes: routes are read and endpoints and schemas of all of them are fetched
+// in batches. Goroutines metric is the peak number of goroutines during polls including the test server ones.
+func BenchmarkPoll5000Routes(b *testing.B) {
+	server := startJolokia(b, 5000)
+	defer server.Close()
+	var consumer MetricConsumer = &MetricConsumerStub{}
+	environment, err := newEnvironment(context.Background(), &InstanceConfig{},
+		&EnvironmentConfig{Name: "dev", Services: []*ServiceConfig{{Name: "smx", Url: server.URL}}},
+		&consumer, nil, nil, nil, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	service := environment.ServiceMap["smx"]
+	defer service.Stop()
+
+	stop := make(chan struct{})
+	var sampler sync.WaitGroup
+	peak := runtime.NumGoroutine()
+	sampler.Add(1)
+	go func() {
+		defer sampler.Done()
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n := runtime.NumGoroutine(); n > peak {
+					peak = n
+				}
+			}
+		}
+	}()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := time.Now()
+		if _, err := service.update(t); err != nil {
+			b.Fatal(err)
+		}
+		routes := service.dueRoutes(t)
+		if len(routes) != 5000 {
+			b.Fatalf("%v routes are due, want 5000", len(routes))
+		}
+		service.updateRoutes(t, routes)
+		service.publish()
+	}
+	b.StopTimer()
+	close(stop)
+	sampler.Wait()
+	for _, route := range service.RouteMap {
+		if route.UpdatingState != UPDATE_STATE_DONE {
+			b.Fatalf("route %s is not updated: %s", route.Name, route.Error)
+		}
+	}
+	b.ReportMetric(float64(peak), "goroutines")
+}
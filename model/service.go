@@ -1,13 +1,16 @@
 package model
 
 import (
+	"context"
 	"time"
 	"sync"
+	"sync/atomic"
 	"errors"
 	"encoding/json"
 	"log"
 	"fmt"
 	"strings"
+	"net/http"
 	"github.com/antchfx/xquery/xml"
 )
 
@@ -20,13 +23,28 @@ const (
 )
 
 const (
-	GetRouteSchemaPath    = "%s/jolokia/exec/org.apache.camel:context=%s,type=routes,name=\"%s\"/dumpRouteAsXml(boolean)/true"
-	GetRouteEndpointsPath = "%s/jolokia/exec/org.apache.camel:context=%s,type=routes,name=\"%s\"/createRouteStaticEndpointJson(boolean)/true"
-	GetRoutesPath         = "/jolokia/read/org.apache.camel:type=routes,*"
+	GetRoutesPath           = "/jolokia/read/org.apache.camel:type=routes,*"
+	BulkPath                = "/jolokia/"
+	RouteMBean              = "org.apache.camel:context=%s,type=routes,name=\"%s\""
+	RouteSchemaOperation    = "dumpRouteAsXml(boolean)"
+	RouteEndpointsOperation = "createRouteStaticEndpointJson(boolean)"
 )
 
 type Instance struct {
+	// live environments, they are read with GetEnvironments
 	Environments []*Environment `json:"environments,omitempty"`
+
+	// guards Environments and environment service maps which are replaced on reload, never changed in place
+	mutex          sync.RWMutex
+	config         *InstanceConfig
+	metricConsumer *MetricConsumer
+	history        *History
+	notifications  *Notifications
+	topology       *Topology
+	updates        *LiveUpdates
+	// parent of service contexts, canceled on shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 type Environment struct {
@@ -42,6 +60,19 @@ func (t JsonTime)MarshalJSON() ([]byte, error) {
 	return []byte(stamp), nil
 }
 
+func (t *JsonTime) UnmarshalJSON(data []byte) error {
+	var stamp string
+	if err := json.Unmarshal(data, &stamp); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(time.RFC3339, stamp)
+	if err != nil {
+		return err
+	}
+	*t = JsonTime(parsed)
+	return nil
+}
+
 type Service struct {
 	Name        string            `json:"name,omitempty"`
 	Url         string            `json:"url,omitempty"`
@@ -51,19 +82,50 @@ type Service struct {
 	Color       string            `json:"color,omitempty"`
 	// IN_PROCESS, DONE, FAILED
 	UpdatingState string     `json:"updatingState,omitempty"`
+	// consecutive failed updates
+	FailedUpdates int        `json:"failedUpdates,omitempty"`
 
-	updateMutex    sync.Mutex
+	// the last published copy of state, see state.go
+	snapshot       atomic.Value
+	settings       chan *serviceSettings
 	metricConsumer *MetricConsumer
+	history        *History
+	notifications  *Notifications
+	topology       *Topology
+	updates        *LiveUpdates
 	config         *ServiceConfig
+	// transport and policy do not change, service is restarted instead
+	transport      *TransportConfig
+	policy         *pollingPolicy
+	client         *http.Client
+	auth           authenticator
+	redactor       *redactor
+	normalizer     *endpointNormalizer
 	environment    *Environment
-	upd            chan time.Time
+	// canceled when service is stopped, done counts its goroutines
+	ctx            context.Context
+	cancel         context.CancelFunc
+	done           sync.WaitGroup
 }
 
-func NewInstance(config *InstanceConfig, metricConsumer *MetricConsumer) (*Instance, error) {
-	instance := &Instance{Environments: make([]*Environment, len(config.Environments))}
+func NewInstance(config *InstanceConfig, metricConsumer *MetricConsumer, history *History,
+	notifications *Notifications, topology *Topology, updates *LiveUpdates) (*Instance, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	instance := &Instance{
+		ctx:            ctx,
+		cancel:         cancel,
+		Environments:   make([]*Environment, len(config.Environments)),
+		config:         config,
+		metricConsumer: metricConsumer,
+		history:        history,
+		notifications:  notifications,
+		topology:       topology,
+		updates:        updates}
 	for i, environmentConfig := range config.Environments {
-		environment, err := NewEnvironment(config, environmentConfig, metricConsumer)
+		environment, err := NewEnvironment(ctx, config, environmentConfig, metricConsumer, history, notifications,
+			topology, updates)
 		if err != nil {
+			cancel()
 			return nil, err
 		}
 		instance.Environments[i] = environment
@@ -71,7 +133,51 @@ func NewInstance(config *InstanceConfig, metricConsumer *MetricConsumer) (*Insta
 	return instance, nil
 }
 
-func NewEnvironment(instanceConfig *InstanceConfig, envConfig *EnvironmentConfig, metricConsumer *MetricConsumer) (*Environment, error) {
+func (instance *Instance) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Environments []*Environment `json:"environments,omitempty"`
+	}{instance.GetEnvironments()})
+}
+
+// Returns published state of environments, it is consistent within every service and safe to read
+func (instance *Instance) GetEnvironments() []*Environment {
+	instance.mutex.RLock()
+	defer instance.mutex.RUnlock()
+	environments := make([]*Environment, len(instance.Environments))
+	for i, environment := range instance.Environments {
+		environments[i] = environment.snapshot()
+	}
+	return environments
+}
+
+// Returns published state of environment by name or nil if there is no such one
+func (instance *Instance) GetEnvironment(name string) *Environment {
+	instance.mutex.RLock()
+	defer instance.mutex.RUnlock()
+	for _, environment := range instance.Environments {
+		if environment.Name == name {
+			return environment.snapshot()
+		}
+	}
+	return nil
+}
+
+func NewEnvironment(ctx context.Context, instanceConfig *InstanceConfig, envConfig *EnvironmentConfig, metricConsumer *MetricConsumer,
+	history *History, notifications *Notifications, topology *Topology, updates *LiveUpdates) (*Environment, error) {
+	environment, err := newEnvironment(ctx, instanceConfig, envConfig, metricConsumer, history, notifications, topology,
+		updates)
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range environment.ServiceMap {
+		service.start()
+	}
+	return environment, nil
+}
+
+// Creates environment with services that are not started, services are canceled if any of them fails
+func newEnvironment(ctx context.Context, instanceConfig *InstanceConfig, envConfig *EnvironmentConfig, metricConsumer *MetricConsumer,
+	history *History, notifications *Notifications, topology *Topology, updates *LiveUpdates) (*Environment, error) {
 	if envConfig.Name == "" {
 		return nil, errors.New("environment name must not be empty")
 	}
@@ -79,8 +185,12 @@ func NewEnvironment(instanceConfig *InstanceConfig, envConfig *EnvironmentConfig
 		Name:       envConfig.Name,
 		ServiceMap: make(map[string]*Service)}
 	for _, serviceConfig := range envConfig.Services {
-		service, err := NewService(instanceConfig, serviceConfig, environment, metricConsumer)
+		service, err := newService(ctx, instanceConfig, envConfig, serviceConfig, environment, metricConsumer,
+			history, notifications, topology, updates)
 		if err != nil {
+			for _, created := range environment.ServiceMap {
+				created.cancel()
+			}
 			return nil, err
 		}
 		environment.ServiceMap[service.Name] = service
@@ -88,71 +198,153 @@ func NewEnvironment(instanceConfig *InstanceConfig, envConfig *EnvironmentConfig
 	return environment, nil
 }
 
-//Creates new service from config
-func NewService(instanceConfig *InstanceConfig, config *ServiceConfig, environment *Environment, metricConsumer *MetricConsumer) (*Service, error) {
+//Creates new service from config, it is polled until ctx is canceled or service is stopped
+func NewService(ctx context.Context, instanceConfig *InstanceConfig, envConfig *EnvironmentConfig, config *ServiceConfig,
+	environment *Environment, metricConsumer *MetricConsumer, history *History, notifications *Notifications,
+	topology *Topology, updates *LiveUpdates) (*Service, error) {
+	service, err := newService(ctx, instanceConfig, envConfig, config, environment, metricConsumer, history,
+		notifications, topology, updates)
+	if err != nil {
+		return nil, err
+	}
+	service.start()
+	return service, nil
+}
+
+// Creates service that is not polled until it is started
+func newService(ctx context.Context, instanceConfig *InstanceConfig, envConfig *EnvironmentConfig, config *ServiceConfig,
+	environment *Environment, metricConsumer *MetricConsumer, history *History, notifications *Notifications,
+	topology *Topology, updates *LiveUpdates) (*Service, error) {
 	if config.Name == "" {
 		return nil, errors.New("service name must not be empty")
 	}
 	if config.Url == "" {
 		return nil, errors.New("service url must not be empty")
 	}
+	policy := resolvePollingPolicy(config.PollingConfig, envConfig.PollingConfig, instanceConfig.PollingConfig)
+	client, err := newHttpClient(config.Transport, policy.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("service %s transport: %s", config.Name, err)
+	}
+	auth, err := newAuthenticator(config.Authorization, client)
+	if err != nil {
+		return nil, fmt.Errorf("service %s authorization: %s", config.Name, err)
+	}
+	normalizer, err := newEndpointNormalizer(instanceConfig.EndpointRules)
+	if err != nil {
+		return nil, err
+	}
 	service := &Service{
 		config:             config,
+		transport:          config.Transport,
+		policy:             policy,
+		client:             client,
+		auth:               auth,
+		redactor:           newRedactor(instanceConfig.Redaction),
+		normalizer:         normalizer,
 		Name:               config.Name,
 		Url:                config.Url,
 		Color:              config.Color,
-		upd:                make(chan time.Time, 100),
+		settings:           make(chan *serviceSettings, 1),
 		RouteMap:           make(map[string]*Route),
 		UpdatingState:      UPDATE_STATE_IN_PROCESS,
 		environment:        environment,
-		metricConsumer:     metricConsumer}
-	go service.doUpdate(instanceConfig.ServiceUpdateIntervalSeconds, instanceConfig.RouteUpdateIntervalSeconds)
+		metricConsumer:     metricConsumer,
+		history:            history,
+		notifications:      notifications,
+		topology:           topology,
+		updates:            updates}
+	service.ctx, service.cancel = context.WithCancel(ctx)
+	service.publish()
 	return service, nil
 }
 
+func (service *Service) start() {
+	service.done.Add(1)
+	go service.doUpdate()
+}
+
 
-func (service *Service) doUpdate(serviceUpdateIntervalSeconds int, routeUpdateIntervalSeconds int) {
-	ticker := time.NewTicker(time.Duration(serviceUpdateIntervalSeconds) * time.Second)
-	service.upd <- time.Now()
+func (service *Service) doUpdate() {
+	defer service.done.Done()
+	// the first update is immediate, the next ones are after jittered interval
+	timer := time.NewTimer(0)
+	routeTicker := time.NewTicker(service.policy.routeSchedulerPeriod())
+	defer timer.Stop()
+	defer routeTicker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			if service.UpdatingState == UPDATE_STATE_IN_PROCESS {
-				//log.Printf("info:  %s:%s is still has been updating", service.environment.Name, service.Name)
-			} else {
-				service.upd <- time.Now()
+		case <-service.ctx.Done():
+			return
+		case settings := <-service.settings:
+			service.applySettings(settings)
+			service.publish()
+		case t := <-routeTicker.C:
+			routes := service.dueRoutes(t)
+			if len(routes) == 0 {
+				continue
+			}
+			service.updateRoutes(t, routes)
+			for _, route := range routes {
+				service.scheduleRoute(route, t, false)
 			}
-		case t := <-service.upd:
+			service.observeTopology(t)
+			service.publish()
+		case t := <-timer.C:
 			service.UpdatingState = UPDATE_STATE_IN_PROCESS
-			err := service.update(t, routeUpdateIntervalSeconds)
+			service.publish()
+			added, err := service.update(t)
+			// new routes are not waiting for the next route update
+			if len(added) > 0 {
+				service.publish()
+			}
+			service.updateRoutes(t, added)
+			for _, route := range added {
+				service.scheduleRoute(route, t, true)
+			}
 			if err != nil {
 				service.UpdatingState = UPDATE_STATE_FAILED
 				service.Error = fmt.Sprintf("%s", err)
+				service.FailedUpdates++
+				if service.FailedUpdates == 1 {
+					service.notify(NOTIFICATION_SERVICE_ERROR, nil, "Service update failed", service.Error, t)
+				}
 			} else {
+				if service.FailedUpdates > 0 {
+					service.notify(NOTIFICATION_SERVICE_RECOVERED, nil, "Service is updated again",
+						fmt.Sprintf("Service is updated after %v failed updates", service.FailedUpdates), t)
+				}
 				service.Error = ""
+				service.FailedUpdates = 0
 				service.UpdatingState = UPDATE_STATE_DONE
 				service.LastUpdated = JsonTime(t)
+				service.observeTopology(t)
 			}
+			service.publish()
+			timer.Reset(jitter(service.policy.serviceUpdateInterval))
 		}
 	}
 }
 
-func (service *Service) update(t time.Time, routeUpdateIntervalSeconds int) error {
+func (service *Service) update(t time.Time) (added []*Route, err error) {
 	url := service.config.Url + GetRoutesPath
-	body, err := callEndpoint(url, service.config.Authorization)
+	body, err := service.call(url)
 	if err != nil {
 		log.Printf("error: %s:%s error during getting routes from %s: %s", service.environment.Name, service.Name,
 			service.config.Url, err)
-		return err
+		return nil, err
 	} else {
+		previousStates := make(map[*Route]string, len(service.RouteMap))
 		for _, r := range service.RouteMap {
+			previousStates[r] = r.State
 			r.State = NONE
 		}
 		// Merge from object
 		response := &ReadRouteResponse{}
 		json.Unmarshal(body, response)
 		//log.Printf("info:  %s:%s read route response %v", service.environment.Name, service.Name, response)
+		historyRecords := make([]*historyRecord, 0, len(response.Value))
 		for _, v := range response.Value {
 			properContext := strings.Replace(v.CamelManagementName, " ", "_", -1)
 			properRouteId := strings.Replace(v.RouteId, " ", "_", -1)
@@ -162,21 +354,22 @@ func (service *Service) update(t time.Time, routeUpdateIntervalSeconds int) erro
 				route = &Route{
 					Context:     v.CamelManagementName,
 					Name:        v.RouteId,
-					EndpointUri: v.EndpointUri,
+					EndpointUri: service.redactor.uri(v.EndpointUri),
 					Endpoints: &Endpoints{
 						Inputs:  make([]string, 0),
 						Outputs: make([]string, 0),
 					},
+					FirstSeen:     JsonTime(t),
 					service:       service,
-					UpdatingState: UPDATE_STATE_IN_PROCESS,
-
-					upd:         make(chan time.Time, 100),
-					metrics: make(chan *Metric, 1000)}
+					UpdatingState: UPDATE_STATE_IN_PROCESS}
 				service.RouteMap[routeName] = route
 				// Add first input
-				route.Endpoints.Inputs = append(route.Endpoints.Inputs, cleanEndpoint(route, v.EndpointUri))
-				go route.doUpdate(routeUpdateIntervalSeconds)
-				go route.sendMetrics()
+				route.Endpoints.Inputs = append(route.Endpoints.Inputs, cleanEndpoint(route, route.EndpointUri))
+				added = append(added, route)
+			} else if route.RemovedAt != nil {
+				log.Printf("info:  %s:%s route %s is back", service.environment.Name, service.Name, route.Name)
+				route.revive(service.redactor.uri(v.EndpointUri))
+				added = append(added, route)
 			}
 			route.State = v.State
 			route.Uptime = v.Uptime
@@ -192,108 +385,149 @@ func (service *Service) update(t time.Time, routeUpdateIntervalSeconds int) erro
 			route.TotalProcessingTime = v.TotalProcessingTime
 			route.FailuresHandled = v.FailuresHandled
 			route.Redeliveries = v.Redeliveries
+			route.StartTimestamp = v.StartTimestamp
+			route.updateRates(&v, t)
 
 			route.collectMetrics(&v, t)
+			historyRecords = append(historyRecords, &historyRecord{
+				context: v.CamelManagementName,
+				route:   v.RouteId,
+				point:   newHistoryPoint(&v, t)})
+		}
+		for r, previousState := range previousStates {
+			if r.State == NONE {
+				r.remove(t)
+			}
+			if r.State != previousState {
+				service.notify(NOTIFICATION_ROUTE_STATE, r, "Route state is changed",
+					fmt.Sprintf("Route %s state is changed from %s to %s", r.Name, previousState, r.State), t)
+			}
+		}
+		service.evictRoutes(t)
+		if service.history != nil {
+			if err := service.history.record(service.environment.Name, service.Name, historyRecords); err != nil {
+				log.Printf("error: %s:%s could not record history: %s", service.environment.Name, service.Name, err)
+			}
 		}
 		// stop update
-		return nil
+		return added, nil
 	}
 }
 
-func (route *Route) doUpdate(routeUpdateIntervalSeconds int) {
-	ticker := time.NewTicker(time.Duration(routeUpdateIntervalSeconds) * time.Second)
-	route.upd <- time.Now()
+// Calls jolokia of service according to its polling policy
+func (service *Service) call(url string) ([]byte, error) {
+	return service.policy.retry(service.ctx, func() ([]byte, error) {
+		return callEndpoint(service.ctx, service.client, url, service.auth)
+	})
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			if route.State == NONE {
-				log.Printf("info:  %s:%s:%s route is outed of service", route.service.environment.Name,
-					route.service.Name, route.Name)
-				return
-			}
-			if route.UpdatingState == UPDATE_STATE_IN_PROCESS {
-				//log.Printf("info:  %s:%s:%s route is still has been updating", route.service.environment.Name,
-				//	route.service.Name, route.Name)
-			} else {
-				//log.Printf("info:  %s:%s:%s route is updating", route.service.environment.Name,
-				//	route.service.Name, route.Name)
-				route.upd <- time.Now()
+// Executes jolokia bulk request, responses are in order of requests
+func (service *Service) bulk(requests []*JolokiaRequest) ([]*ReadResponse, error) {
+	payload, err := json.Marshal(requests)
+	if err != nil {
+		return nil, err
+	}
+	body, err := service.policy.retry(service.ctx, func() ([]byte, error) {
+		return postEndpoint(service.ctx, service.client, service.config.Url+BulkPath, payload, service.auth)
+	})
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]*ReadResponse, 0, len(requests))
+	if err = json.Unmarshal(body, &responses); err != nil {
+		return nil, err
+	}
+	if len(responses) != len(requests) {
+		return nil, fmt.Errorf("%v responses are received for %v requests", len(responses), len(requests))
+	}
+	return responses, nil
+}
+
+// Stops polling of service and its routes, requests in flight are canceled
+func (service *Service) Stop() {
+	service.cancel()
+}
+
+// Sends notification about service or its route
+func (service *Service) notify(notificationType string, route *Route, title string, message string, t time.Time) {
+	notification := &Notification{
+		Type:        notificationType,
+		Environment: service.environment.Name,
+		Service:     service.Name,
+		Title:       title,
+		Message:     message,
+		Time:        JsonTime(t)}
+	if route != nil {
+		notification.Context = route.Context
+		notification.Route = route.Name
+	}
+	service.notifications.Notify(notification)
+}
+
+// Updates endpoints and schemas of routes with jolokia bulk requests, failures are reported per route
+func (service *Service) updateRoutes(t time.Time, routes []*Route) {
+	if len(routes) == 0 {
+		return
+	}
+	for _, route := range routes {
+		route.UpdatingState = UPDATE_STATE_IN_PROCESS
+	}
+	for _, batch := range service.fetchRoutes(routes) {
+		for i, route := range batch.routes {
+			routeErr := batch.err
+			if routeErr == nil {
+				routeErr = route.update(batch.responses[2*i], batch.responses[2*i+1])
 			}
-		case t := <-route.upd:
-			route.UpdatingState = UPDATE_STATE_IN_PROCESS
-			err:= route.update()
-			if err != nil {
+			if routeErr != nil {
 				route.UpdatingState = UPDATE_STATE_FAILED
-				route.Error = fmt.Sprintf("%s", err)
-				if route.State == NONE {
-					//close(route.upd)
-					//close(route.metrics)
-				}
+				route.Error = fmt.Sprintf("%s", routeErr)
 			} else {
 				route.Error = ""
 				route.UpdatingState = UPDATE_STATE_DONE
 				route.LastUpdated = JsonTime(t)
+				route.topology = route.topologyRoute()
 			}
 		}
 	}
 }
 
-func (route *Route) update() error {
+func (route *Route) update(endpointsResponse *ReadResponse, schemaResponse *ReadResponse) error {
 	// Endpoints
-	url := fmt.Sprintf(GetRouteEndpointsPath, route.service.config.Url, route.Context, route.Name)
-
-	//log.Printf("indo: %s:%s:%s getting route endoints from %s",
-	//	route.service.environment.Name, route.service.Name, route.Name, url)
-
-	body, err := callEndpoint(url, route.service.config.Authorization)
-	if err != nil {
+	if endpointsResponse.Status != 200 {
 		log.Printf("error: %s:%s:%s error during getting route endoints from %s: %s",
-			route.service.environment.Name, route.service.Name, route.Name, route.service.config.Url, err)
-		return err
+			route.service.environment.Name, route.service.Name, route.Name, route.service.config.Url,
+			endpointsResponse.Error)
+		return errors.New(endpointsResponse.Error)
 	} else {
-		response := &ReadResponse{}
-		json.Unmarshal(body, response)
-		if response.Status != 200 {
-			log.Printf("error: %s:%s:%s error during getting route endoints from %s: %s",
-				route.service.environment.Name, route.service.Name, route.Name, route.service.config.Url, response.Error)
-			return errors.New(response.Error)
-		} else {
-			endpointsEntry := &ReadRoutesEndpointsEntry{}
-			json.Unmarshal([]byte(response.Value), endpointsEntry)
-			if endpointsEntry != nil && *endpointsEntry.Routes != nil{
-				for _, v := range *endpointsEntry.Routes {
-					if v.Outputs == nil {
+		endpointsEntry := &ReadRoutesEndpointsEntry{}
+		json.Unmarshal([]byte(endpointsResponse.Value), endpointsEntry)
+		if endpointsEntry.Routes != nil {
+			for _, v := range *endpointsEntry.Routes {
+				if v.Outputs == nil {
+					continue
+				}
+				for _, o := range v.Outputs {
+
+					// skip configured and not filled endpoints
+					if len(o.Uri) == 0 && strings.Contains(o.Uri, "{{") {
 						continue
 					}
-					for _, o := range v.Outputs {
-
-						// skip configured and not filled endpoints
-						if len(o.Uri) == 0 && strings.Contains(o.Uri, "{{") {
-							continue
-						}
-						candidate := cleanEndpoint(route, o.Uri)
-						if !contains(route.Endpoints.Outputs, candidate) {
-							route.Endpoints.Outputs = append(route.Endpoints.Outputs, candidate)
-						}
+					candidate := cleanEndpoint(route, route.service.redactor.uri(o.Uri))
+					if !contains(route.Endpoints.Outputs, candidate) {
+						route.Endpoints.Outputs = append(route.Endpoints.Outputs, candidate)
 					}
 				}
 			}
 		}
 	}
 	// Schema
-	url = fmt.Sprintf(GetRouteSchemaPath, route.service.config.Url, route.Context, route.Name)
-	//log.Printf("info:  %s:%s:%s getting schema %s", route.service.environment.Name, route.service.Name, route.Name, url)
-	body, err = callEndpoint(url, route.service.config.Authorization)
-	if err != nil {
+	if schemaResponse.Status != 200 {
 		log.Printf("error: %s:%s:%s error during getting schema from %s: %s", route.service.environment.Name,
-			route.service.Name, route.Name, route.service.config.Url, err)
-		return err
+			route.service.Name, route.Name, route.service.config.Url, schemaResponse.Error)
+		return errors.New(schemaResponse.Error)
 	} else {
-		response := &ReadResponse{}
-		json.Unmarshal(body, response)
-		if response != nil && len(response.Value) > 0 {
-			route.Schema = response.Value
+		if len(schemaResponse.Value) > 0 {
+			route.Schema = route.service.redactor.schema(schemaResponse.Value)
 			xmlNode, _ := xmlquery.Parse(strings.NewReader(route.Schema))
 			// to endpoints
 			toEndpoints := xmlquery.Find(xmlNode, "//to")
@@ -344,23 +578,43 @@ func cleanEndpoints(route *Route, v []*RouteEndpointEntry) (inputs []string) {
 }
 
 func cleanEndpoint(route *Route, endpoint string) (result string) {
-	endpoint = strings.Replace(endpoint, "%7B", "{", -1)
-	endpoint = strings.Replace(endpoint, "%7D", "}", -1)
-	endpoint = strings.Replace(endpoint, "://", ":", -1)
-	endpoint = strings.Replace(endpoint, "activemq:", "jms:", -1)
-	//take left part before ?
-	endpoint = strings.Split(endpoint, "?")[0]
-	if strings.Contains(endpoint, "VirtualTopic") {
-		topicName := strings.Split(endpoint, "VirtualTopic")[1]
-		endpoint = "VirtualTopic" + topicName
-	}
-	if strings.HasPrefix(endpoint, "direct") {
-		endpoint = route.service.Name + ":" + endpoint
-	}
-	if strings.HasPrefix(endpoint, "timer") {
-		endpoint = route.service.Name + ":" + endpoint
-	}
-	return endpoint
+	return route.service.normalizer.normalize(route.service.Name, endpoint)
+}
+
+// Computes deltas and rates since previous poll, counters are taken as is after context restart
+func (route *Route) updateRates(e *ReadRouteEntry, t time.Time) {
+	previous := route.lastSample
+	route.lastSample = &routeSample{
+		time:            t,
+		startTimestamp:  e.StartTimestamp,
+		exchangesTotal:  e.ExchangesTotal,
+		exchangesFailed: e.ExchangesFailed}
+	if previous == nil || !t.After(previous.time) {
+		// rates of the previous presence or sample are not shown as current ones
+		route.RatesComputed = false
+		route.ExchangesDelta = 0
+		route.ExchangesFailedDelta = 0
+		route.ExchangesPerSecond = 0
+		route.FailuresPerSecond = 0
+		route.FailureRatio = 0
+		return
+	}
+	exchangesDelta := e.ExchangesTotal - previous.exchangesTotal
+	failedDelta := e.ExchangesFailed - previous.exchangesFailed
+	if e.StartTimestamp != previous.startTimestamp || exchangesDelta < 0 || failedDelta < 0 {
+		exchangesDelta = e.ExchangesTotal
+		failedDelta = e.ExchangesFailed
+	}
+	seconds := t.Sub(previous.time).Seconds()
+	route.ExchangesDelta = exchangesDelta
+	route.ExchangesFailedDelta = failedDelta
+	route.ExchangesPerSecond = float64(exchangesDelta) / seconds
+	route.FailuresPerSecond = float64(failedDelta) / seconds
+	route.FailureRatio = 0
+	if exchangesDelta > 0 {
+		route.FailureRatio = float64(failedDelta) / float64(exchangesDelta)
+	}
+	route.RatesComputed = true
 }
 
 func (route *Route) collectMetrics(e *ReadRouteEntry, t time.Time) {
@@ -370,24 +624,34 @@ func (route *Route) collectMetrics(e *ReadRouteEntry, t time.Time) {
 	properRouteName := strings.Replace(routeName, ".", "_", -1)
 
 	serviceName := fmt.Sprintf("camel-graph.%s.%s.%s", route.service.environment.Name, route.service.Name, properRouteName)
-	route.metrics <- NewMetric(fmt.Sprintf("%s.%s", serviceName, "exchanges_total"), e.ExchangesTotal, t)
-	route.metrics <- NewMetric(fmt.Sprintf("%s.%s", serviceName, "exchanges_completed"), e.ExchangesCompleted, t)
-	route.metrics <- NewMetric(fmt.Sprintf("%s.%s", serviceName, "exchanges_failed"), e.ExchangesFailed, t)
-	route.metrics <- NewMetric(fmt.Sprintf("%s.%s", serviceName, "exchanges_inflight"), e.ExchangesInflight, t)
-	route.metrics <- NewMetric(fmt.Sprintf("%s.%s", serviceName, "max_processing_time"), e.MaxProcessingTime, t)
-	route.metrics <- NewMetric(fmt.Sprintf("%s.%s", serviceName, "min_processing_time"), e.MinProcessingTime, t)
-	route.metrics <- NewMetric(fmt.Sprintf("%s.%s", serviceName, "last_processing_time"), e.LastProcessingTime, t)
-	route.metrics <- NewMetric(fmt.Sprintf("%s.%s", serviceName, "mean_processing_time"), e.MeanProcessingTime, t)
-	route.metrics <- NewMetric(fmt.Sprintf("%s.%s", serviceName, "total_processing_time"), e.TotalProcessingTime, t)
-	route.metrics <- NewMetric(fmt.Sprintf("%s.%s", serviceName, "failures_handled"), e.FailuresHandled, t)
-	route.metrics <- NewMetric(fmt.Sprintf("%s.%s", serviceName, "redeliveries"), e.Redeliveries, t)
-}
-
-func (route *Route) sendMetrics() {
-	for {
-		select {
-		case metric := <-route.metrics:
-			(*route.service.metricConsumer).consumeMetric(metric)
-		}
+	labels := map[string]string{
+		"environment": route.service.environment.Name,
+		"service":     route.service.Name,
+		"context":     e.CamelManagementName,
+		"route":       e.RouteId}
+	newMetric := func(series string, value interface{}) *Metric {
+		metric := NewMetric(fmt.Sprintf("%s.%s", serviceName, series), value, t)
+		metric.series = series
+		metric.labels = labels
+		metric.ttl = MetricTtlIntervals * route.service.policy.serviceUpdateInterval
+		return metric
+	}
+	// consumer does not block, sinks have own buffers
+	consumer := *route.service.metricConsumer
+	consumer.consumeMetric(newMetric("exchanges_total", e.ExchangesTotal))
+	consumer.consumeMetric(newMetric("exchanges_completed", e.ExchangesCompleted))
+	consumer.consumeMetric(newMetric("exchanges_failed", e.ExchangesFailed))
+	consumer.consumeMetric(newMetric("exchanges_inflight", e.ExchangesInflight))
+	consumer.consumeMetric(newMetric("max_processing_time", e.MaxProcessingTime))
+	consumer.consumeMetric(newMetric("min_processing_time", e.MinProcessingTime))
+	consumer.consumeMetric(newMetric("last_processing_time", e.LastProcessingTime))
+	consumer.consumeMetric(newMetric("mean_processing_time", e.MeanProcessingTime))
+	consumer.consumeMetric(newMetric("total_processing_time", e.TotalProcessingTime))
+	consumer.consumeMetric(newMetric("failures_handled", e.FailuresHandled))
+	consumer.consumeMetric(newMetric("redeliveries", e.Redeliveries))
+	if route.RatesComputed {
+		consumer.consumeMetric(newMetric("exchanges_per_second", route.ExchangesPerSecond))
+		consumer.consumeMetric(newMetric("failures_per_second", route.FailuresPerSecond))
+		consumer.consumeMetric(newMetric("failure_ratio", route.FailureRatio))
 	}
 }
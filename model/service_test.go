@@ -0,0 +1,31 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUpdateRatesResetsRatesWithoutPreviousSample(t *testing.T) {
+	route := &Route{}
+	start := time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC)
+	route.updateRates(&ReadRouteEntry{ExchangesTotal: 10, ExchangesFailed: 1, StartTimestamp: "a"}, start)
+	if route.RatesComputed {
+		t.Error("rates are computed without previous sample")
+	}
+	route.updateRates(&ReadRouteEntry{ExchangesTotal: 30, ExchangesFailed: 6, StartTimestamp: "a"},
+		start.Add(10*time.Second))
+	if !route.RatesComputed || route.ExchangesDelta != 20 || route.ExchangesPerSecond != 2 ||
+		route.FailuresPerSecond != 0.5 || route.FailureRatio != 0.25 {
+		t.Fatalf("unexpected rates %+v", route)
+	}
+	// sample of the same time and sample after revive do not keep old rates
+	for _, reset := range []func(){func() {}, func() { route.lastSample = nil }} {
+		reset()
+		route.updateRates(&ReadRouteEntry{ExchangesTotal: 40, ExchangesFailed: 6, StartTimestamp: "a"},
+			start.Add(10*time.Second))
+		if route.RatesComputed || route.ExchangesDelta != 0 || route.ExchangesFailedDelta != 0 ||
+			route.ExchangesPerSecond != 0 || route.FailuresPerSecond != 0 || route.FailureRatio != 0 {
+			t.Errorf("stale rates %+v", route)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"context"
+)
+
+// Stops polling of every service, requests in flight are canceled. Waits until pollers exit and pass on
+// collected metrics or ctx is done.
+func (instance *Instance) Shutdown(ctx context.Context) error {
+	instance.mutex.Lock()
+	instance.cancel()
+	services := make([]*Service, 0)
+	for _, environment := range instance.Environments {
+		for _, service := range environment.ServiceMap {
+			services = append(services, service)
+		}
+	}
+	instance.mutex.Unlock()
+	return waitUntil(ctx, func() {
+		for _, service := range services {
+			service.done.Wait()
+		}
+	})
+}
+
+// Calls wait in background, returns ctx error if it is done before wait returns
+func waitUntil(ctx context.Context, wait func()) error {
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+// State of a service is owned by its polling goroutine: RouteMap and routes are changed only there.
+// After every change the goroutine publishes a copy which readers get without locking, published copies
+// are never changed.
+
+// Settings that are changed on reload without service restart
+type serviceSettings struct {
+	config *ServiceConfig
+	auth   authenticator
+}
+
+// Returns the last published state of service
+func (service *Service) Snapshot() *Service {
+	return service.snapshot.Load().(*Service)
+}
+
+// Publishes copy of current state, it is called by polling goroutine only
+func (service *Service) publish() {
+	snapshot := &Service{
+		Name:          service.Name,
+		Url:           service.Url,
+		RouteMap:      make(map[string]*Route, len(service.RouteMap)),
+		LastUpdated:   service.LastUpdated,
+		Error:         service.Error,
+		Color:         service.Color,
+		UpdatingState: service.UpdatingState,
+		FailedUpdates: service.FailedUpdates}
+	for name, route := range service.RouteMap {
+		snapshot.RouteMap[name] = route.copy()
+	}
+	previous, _ := service.snapshot.Load().(*Service)
+	service.snapshot.Store(snapshot)
+	if previous != nil && service.updates.watched(service.environment.Name) {
+		service.updates.sendChanges(service.environment.Name, previous, snapshot)
+	}
+}
+
+// Passes settings to polling goroutine, settings that are not applied yet are replaced
+func (service *Service) reconfigure(settings *serviceSettings) {
+	select {
+	case <-service.settings:
+	default:
+	}
+	service.settings <- settings
+}
+
+func (service *Service) applySettings(settings *serviceSettings) {
+	service.config = settings.config
+	service.Color = settings.config.Color
+	service.auth = settings.auth
+}
+
+// Copy of route without links to service, endpoints are copied as they grow in place
+func (route *Route) copy() *Route {
+	copied := *route
+	copied.service = nil
+	copied.lastSample = nil
+	if route.Endpoints != nil {
+		copied.Endpoints = &Endpoints{
+			Inputs:  append([]string(nil), route.Endpoints.Inputs...),
+			Outputs: append([]string(nil), route.Endpoints.Outputs...)}
+	}
+	return &copied
+}
+
+// Environment with published states of its services
+func (environment *Environment) snapshot() *Environment {
+	snapshot := &Environment{
+		Name:       environment.Name,
+		ServiceMap: make(map[string]*Service, len(environment.ServiceMap))}
+	for name, service := range environment.ServiceMap {
+		snapshot.ServiceMap[name] = service.Snapshot()
+	}
+	return snapshot
+}
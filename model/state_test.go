@@ -0,0 +1,172 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Jolokia stand-in with given number of routes, counters of routes grow on every read
+func startJolokia(tb testing.TB, routes int) *httptest.Server {
+	names := make([]string, routes)
+	for i := range names {
+		names[i] = fmt.Sprintf("route%v", i)
+	}
+	return httptest.NewServer(jolokiaHandler(tb, func() []string { return names }))
+}
+
+// Jolokia handler with routes of camel context that are returned by routes on every read
+func jolokiaHandler(tb testing.TB, routes func() []string) http.Handler {
+	var reads int64
+	endpoints, _ := json.Marshal(&ReadRoutesEndpointsEntry{Routes: &map[string]*ReadRouteEndpointsEntry{
+		"route": {
+			Inputs:  []*RouteEndpointEntry{{Uri: "activemq://queue:in?concurrentConsumers=5"}},
+			Outputs: []*RouteEndpointEntry{{Uri: "direct://out"}, {Uri: "jms:queue:Consumer.a.VirtualTopic.out"}}}}})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			requests := make([]*JolokiaRequest, 0)
+			if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+				tb.Errorf("could not read bulk request: %s", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			responses := make([]*ReadResponse, len(requests))
+			for i, request := range requests {
+				if request.Operation == RouteEndpointsOperation {
+					responses[i] = &ReadResponse{Status: 200, Value: string(endpoints)}
+				} else {
+					responses[i] = &ReadResponse{Status: 200, Value: `<route><from uri="activemq:queue:in"/></route>`}
+				}
+			}
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+		total := int(atomic.AddInt64(&reads, 1))
+		names := routes()
+		response := &ReadRouteResponse{Status: 200, Value: make(map[string]ReadRouteEntry, len(names))}
+		for _, name := range names {
+			response.Value[name] = ReadRouteEntry{
+				CamelManagementName: "camel",
+				RouteId:             name,
+				EndpointUri:         "activemq://queue:in",
+				State:               "Started",
+				ExchangesTotal:      total,
+				ExchangesCompleted:  total}
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+// State is read by handlers and live update streams while services are polled and config is reloaded
+func TestInstanceStateIsReadWhilePollingAndReloading(t *testing.T) {
+	server := startJolokia(t, 20)
+	defer server.Close()
+	var consumer MetricConsumer = &MetricConsumerStub{}
+	updates := NewLiveUpdates()
+	config := func(color string, services ...string) *InstanceConfig {
+		environment := &EnvironmentConfig{Name: "dev"}
+		for _, name := range services {
+			environment.Services = append(environment.Services, &ServiceConfig{Name: name, Url: server.URL, Color: color})
+		}
+		return &InstanceConfig{
+			PollingConfig: PollingConfig{ServiceUpdateIntervalSeconds: 1, RouteUpdateIntervalSeconds: 1},
+			Environments:  []*EnvironmentConfig{environment}}
+	}
+	instance, err := NewInstance(config("#000", "a", "b"), &consumer, nil, nil, nil, updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	var received int64
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := json.Marshal(instance); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := json.Marshal(instance.GetEnvironments()); err != nil {
+					t.Error(err)
+					return
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				stream, unsubscribe := updates.Subscribe("dev")
+				for open := true; open; {
+					select {
+					case <-stop:
+						unsubscribe()
+						return
+					case update, ok := <-stream:
+						if open = ok; ok {
+							if _, err := json.Marshal(update); err != nil {
+								t.Error(err)
+							}
+							atomic.AddInt64(&received, 1)
+						}
+					}
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// colors are changed in place, service c is started and stopped
+		configs := []*InstanceConfig{config("#111", "a", "b", "c"), config("#222", "a", "b"), config("#333", "b", "c")}
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(200 * time.Millisecond):
+			}
+			if err := instance.Reload(configs[i%len(configs)]); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	time.Sleep(3 * time.Second)
+	close(stop)
+	wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := instance.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	updates.Close()
+	if atomic.LoadInt64(&received) == 0 {
+		t.Error("no live updates are received")
+	}
+	updated := false
+	for _, environment := range instance.GetEnvironments() {
+		for _, service := range environment.ServiceMap {
+			if route := service.RouteMap["camel.route0"]; route != nil && len(route.Endpoints.Outputs) > 0 {
+				updated = true
+			}
+		}
+	}
+	if !updated {
+		t.Error("endpoints of routes are not updated")
+	}
+}
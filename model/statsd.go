@@ -0,0 +1,32 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+)
+
+// Sends metrics to statsd as gauges over udp
+type Statsd struct {
+	conn net.Conn
+}
+
+func NewStatsd(url string) (*Statsd, error) {
+	conn, err := net.Dial("udp", url)
+	if err != nil {
+		return nil, err
+	}
+	return &Statsd{conn: conn}, nil
+}
+
+func (statsd *Statsd) Close(ctx context.Context) error {
+	return statsd.conn.Close()
+}
+
+func (statsd *Statsd) consumeMetric(metric *Metric) {
+	message := fmt.Sprintf("%s:%v|g\n", metric.name, metric.value)
+	if _, err := statsd.conn.Write([]byte(message)); err != nil {
+		log.Printf("error: could not send metric to statsd: %s", err)
+	}
+}
@@ -0,0 +1,396 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	DefaultTopologyFile          = "topology.db"
+	DefaultTopologyRetentionDays = 90
+
+	TOPOLOGY_ROUTE_ADDED    = "route_added"
+	TOPOLOGY_ROUTE_REMOVED  = "route_removed"
+	TOPOLOGY_INPUT_ADDED    = "input_added"
+	TOPOLOGY_INPUT_REMOVED  = "input_removed"
+	TOPOLOGY_OUTPUT_ADDED   = "output_added"
+	TOPOLOGY_OUTPUT_REMOVED = "output_removed"
+	TOPOLOGY_SCHEMA_CHANGED = "schema_changed"
+)
+
+var (
+	topologySnapshotsBucket = []byte("snapshots")
+	topologyEventsBucket    = []byte("events")
+)
+
+// Snapshots of environment topology stored on every change together with change events.
+// Snapshots and events are kept in buckets snapshots -> environment and events -> environment keyed by time.
+type Topology struct {
+	db        *bolt.DB
+	retention time.Duration
+	stop      chan struct{}
+	mutex     sync.Mutex
+	// the last snapshot of every environment
+	current map[string]*TopologySnapshot
+}
+
+type TopologySnapshot struct {
+	Environment string           `json:"environment"`
+	Time        JsonTime         `json:"time"`
+	Routes      []*TopologyRoute `json:"routes"`
+}
+
+type TopologyRoute struct {
+	Service    string   `json:"service"`
+	Context    string   `json:"context"`
+	Route      string   `json:"route"`
+	Inputs     []string `json:"inputs,omitempty"`
+	Outputs    []string `json:"outputs,omitempty"`
+	SchemaHash string   `json:"schemaHash,omitempty"`
+}
+
+type TopologyEvent struct {
+	Time        JsonTime `json:"time"`
+	Environment string   `json:"environment"`
+	Type        string   `json:"type"`
+	Service     string   `json:"service"`
+	Context     string   `json:"context"`
+	Route       string   `json:"route"`
+	Endpoint    string   `json:"endpoint,omitempty"`
+	SchemaHash  string   `json:"schemaHash,omitempty"`
+}
+
+func NewTopology(config *TopologyConfig) (*Topology, error) {
+	file := config.File
+	if file == "" {
+		file = DefaultTopologyFile
+	}
+	retentionDays := config.RetentionDays
+	if retentionDays <= 0 {
+		retentionDays = DefaultTopologyRetentionDays
+	}
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	topology := &Topology{
+		db:        db,
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+		stop:      make(chan struct{}),
+		current:   make(map[string]*TopologySnapshot)}
+	// services that are not polled yet keep routes of the last snapshot
+	err = db.View(func(tx *bolt.Tx) error {
+		snapshots := tx.Bucket(topologySnapshotsBucket)
+		if snapshots == nil {
+			return nil
+		}
+		return snapshots.ForEach(func(environment []byte, v []byte) error {
+			_, value := snapshots.Bucket(environment).Cursor().Last()
+			if value == nil {
+				return nil
+			}
+			snapshot := &TopologySnapshot{}
+			if err := json.Unmarshal(value, snapshot); err != nil {
+				return err
+			}
+			topology.current[string(environment)] = snapshot
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	go topology.cleanup()
+	return topology, nil
+}
+
+// Replaces routes of service in topology of environment, snapshot and events are stored if anything is changed
+func (topology *Topology) observe(environment string, service string, routes []*TopologyRoute, t time.Time) {
+	topology.mutex.Lock()
+	defer topology.mutex.Unlock()
+
+	previous := topology.current[environment]
+	snapshot := &TopologySnapshot{Environment: environment, Time: JsonTime(t), Routes: make([]*TopologyRoute, 0)}
+	before := make(map[string]*TopologyRoute)
+	if previous != nil {
+		for _, route := range previous.Routes {
+			if route.Service == service {
+				before[route.Context+"/"+route.Route] = route
+			} else {
+				snapshot.Routes = append(snapshot.Routes, route)
+			}
+		}
+	}
+	events := make([]*TopologyEvent, 0)
+	newEvent := func(eventType string, route *TopologyRoute) *TopologyEvent {
+		return &TopologyEvent{
+			Time:        JsonTime(t),
+			Environment: environment,
+			Type:        eventType,
+			Service:     service,
+			Context:     route.Context,
+			Route:       route.Route}
+	}
+	after := make(map[string]bool)
+	for _, route := range routes {
+		key := route.Context + "/" + route.Route
+		after[key] = true
+		snapshot.Routes = append(snapshot.Routes, route)
+		old, exists := before[key]
+		if !exists {
+			events = append(events, newEvent(TOPOLOGY_ROUTE_ADDED, route))
+			continue
+		}
+		endpointEvents := func(eventType string, a []string, b []string) {
+			for _, endpoint := range a {
+				if !contains(b, endpoint) {
+					event := newEvent(eventType, route)
+					event.Endpoint = endpoint
+					events = append(events, event)
+				}
+			}
+		}
+		endpointEvents(TOPOLOGY_INPUT_ADDED, route.Inputs, old.Inputs)
+		endpointEvents(TOPOLOGY_INPUT_REMOVED, old.Inputs, route.Inputs)
+		endpointEvents(TOPOLOGY_OUTPUT_ADDED, route.Outputs, old.Outputs)
+		endpointEvents(TOPOLOGY_OUTPUT_REMOVED, old.Outputs, route.Outputs)
+		if route.SchemaHash != old.SchemaHash {
+			event := newEvent(TOPOLOGY_SCHEMA_CHANGED, route)
+			event.SchemaHash = route.SchemaHash
+			events = append(events, event)
+		}
+	}
+	for key, route := range before {
+		if !after[key] {
+			events = append(events, newEvent(TOPOLOGY_ROUTE_REMOVED, route))
+		}
+	}
+	if len(events) == 0 {
+		return
+	}
+	sort.Slice(snapshot.Routes, func(i, j int) bool {
+		a, b := snapshot.Routes[i], snapshot.Routes[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		if a.Context != b.Context {
+			return a.Context < b.Context
+		}
+		return a.Route < b.Route
+	})
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Context+"/"+events[i].Route < events[j].Context+"/"+events[j].Route
+	})
+	if err := topology.store(snapshot, events); err != nil {
+		log.Printf("error: %s:%s could not store topology: %s", environment, service, err)
+		return
+	}
+	topology.current[environment] = snapshot
+}
+
+func (topology *Topology) store(snapshot *TopologySnapshot, events []*TopologyEvent) error {
+	return topology.db.Update(func(tx *bolt.Tx) error {
+		t := time.Time(snapshot.Time)
+		snapshots, err := createBuckets(tx, string(topologySnapshotsBucket), snapshot.Environment)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(snapshot)
+		if err != nil {
+			return err
+		}
+		if err = snapshots.Put(historyKey(t), value); err != nil {
+			return err
+		}
+		eventsBucket, err := createBuckets(tx, string(topologyEventsBucket), snapshot.Environment)
+		if err != nil {
+			return err
+		}
+		for i, event := range events {
+			value, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			// events of one change share time, index keeps them apart and ordered
+			key := make([]byte, 12)
+			copy(key, historyKey(t))
+			binary.BigEndian.PutUint32(key[8:], uint32(i))
+			if err = eventsBucket.Put(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Returns change events of environment within time range
+func (topology *Topology) Events(environment string, from time.Time, to time.Time) ([]*TopologyEvent, error) {
+	result := make([]*TopologyEvent, 0)
+	err := topology.db.View(func(tx *bolt.Tx) error {
+		bucket := findBucket(tx, string(topologyEventsBucket), environment)
+		if bucket == nil {
+			return nil
+		}
+		max := historyKey(to)
+		cursor := bucket.Cursor()
+		for k, v := cursor.Seek(historyKey(from)); k != nil && bytes.Compare(k[:8], max) <= 0; k, v = cursor.Next() {
+			event := &TopologyEvent{}
+			if err := json.Unmarshal(v, event); err != nil {
+				return err
+			}
+			result = append(result, event)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Returns snapshot of environment that was actual at given time, nil if there was none
+func (topology *Topology) Snapshot(environment string, at time.Time) (*TopologySnapshot, error) {
+	var snapshot *TopologySnapshot
+	err := topology.db.View(func(tx *bolt.Tx) error {
+		bucket := findBucket(tx, string(topologySnapshotsBucket), environment)
+		if bucket == nil {
+			return nil
+		}
+		key := historyKey(at)
+		cursor := bucket.Cursor()
+		k, v := cursor.Seek(key)
+		if k == nil || bytes.Compare(k, key) > 0 {
+			k, v = cursor.Prev()
+		}
+		if k == nil {
+			return nil
+		}
+		snapshot = &TopologySnapshot{}
+		return json.Unmarshal(v, snapshot)
+	})
+	return snapshot, err
+}
+
+// Removes snapshots and events that are older than retention, the last snapshot is kept
+func (topology *Topology) cleanup() {
+	ticker := time.NewTicker(HistoryCleanupInterval)
+	defer ticker.Stop()
+	for {
+		deadline := historyKey(time.Now().Add(-topology.retention))
+		err := topology.db.Update(func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{topologySnapshotsBucket, topologyEventsBucket} {
+				root := tx.Bucket(name)
+				if root == nil {
+					continue
+				}
+				err := root.ForEach(func(environment []byte, v []byte) error {
+					bucket := root.Bucket(environment)
+					cursor := bucket.Cursor()
+					last, _ := cursor.Last()
+					expired := make([][]byte, 0)
+					for k, _ := cursor.First(); k != nil && bytes.Compare(k[:8], deadline) < 0; k, _ = cursor.Next() {
+						if bytes.Equal(name, topologySnapshotsBucket) && bytes.Equal(k, last) {
+							break
+						}
+						expired = append(expired, k)
+					}
+					for _, k := range expired {
+						if err := bucket.Delete(k); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("error: could not clean up topology: %s", err)
+		}
+		select {
+		case <-topology.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stops cleanup and closes store, it must be called after pollers are stopped
+func (topology *Topology) Close() error {
+	close(topology.stop)
+	return topology.db.Close()
+}
+
+// Routes of service in the last snapshot of environment keyed by context and route
+func (topology *Topology) serviceRoutes(environment string, service string) map[string]*TopologyRoute {
+	topology.mutex.Lock()
+	defer topology.mutex.Unlock()
+	routes := make(map[string]*TopologyRoute)
+	if snapshot := topology.current[environment]; snapshot != nil {
+		for _, route := range snapshot.Routes {
+			if route.Service == service {
+				routes[route.Context+"/"+route.Route] = route
+			}
+		}
+	}
+	return routes
+}
+
+// Topology of service routes that are present in the last response. Routes whose endpoints and schema
+// could not be fetched keep the last fetched topology, routes that were never fetched keep the recorded one.
+func (service *Service) topologyRoutes() []*TopologyRoute {
+	routes := make([]*TopologyRoute, 0, len(service.RouteMap))
+	var recorded map[string]*TopologyRoute
+	for _, route := range service.RouteMap {
+		if route.State == NONE {
+			continue
+		}
+		if route.topology != nil {
+			routes = append(routes, route.topology)
+			continue
+		}
+		if recorded == nil {
+			recorded = service.topology.serviceRoutes(service.environment.Name, service.Name)
+		}
+		if topologyRoute, exists := recorded[route.Context+"/"+route.Name]; exists {
+			routes = append(routes, topologyRoute)
+		}
+	}
+	return routes
+}
+
+func (route *Route) topologyRoute() *TopologyRoute {
+	topologyRoute := &TopologyRoute{Service: route.service.Name, Context: route.Context, Route: route.Name}
+	if route.Endpoints != nil {
+		topologyRoute.Inputs = append([]string(nil), route.Endpoints.Inputs...)
+		topologyRoute.Outputs = append([]string(nil), route.Endpoints.Outputs...)
+	}
+	if route.Schema != "" {
+		sum := sha256.Sum256([]byte(route.Schema))
+		topologyRoute.SchemaHash = hex.EncodeToString(sum[:8])
+	}
+	return topologyRoute
+}
+
+// Records topology of service if topology is on, stopped service is already removed from topology
+func (service *Service) observeTopology(t time.Time) {
+	if service.topology == nil {
+		return
+	}
+	select {
+	case <-service.ctx.Done():
+		return
+	default:
+	}
+	service.topology.observe(service.environment.Name, service.Name, service.topologyRoutes(), t)
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestTopologyRoutesKeepTopologyOfNotFetchedRoutes(t *testing.T) {
+	recorded := &TopologyRoute{Service: "smx", Context: "camel", Route: "recorded", Inputs: []string{"jms:queue:a"}}
+	topology := &Topology{current: map[string]*TopologySnapshot{"dev": {Environment: "dev", Routes: []*TopologyRoute{
+		recorded,
+		{Service: "smx", Context: "camel", Route: "failed", Inputs: []string{"jms:queue:old"}},
+		{Service: "other", Context: "camel", Route: "unknown"}}}}}
+	service := &Service{Name: "smx", RouteMap: make(map[string]*Route), topology: topology,
+		environment: &Environment{Name: "dev"}}
+	addRoute := func(name string, state string, inputs ...string) *Route {
+		route := &Route{Context: "camel", Name: name, State: state, Endpoints: &Endpoints{Inputs: inputs},
+			service: service}
+		service.RouteMap["camel."+name] = route
+		return route
+	}
+	fetched := addRoute("fetched", "Started", "jms:queue:fetched")
+	fetched.topology = fetched.topologyRoute()
+	// endpoints of failed route were changed by the last update but it keeps the last fetched topology
+	failed := addRoute("failed", "Started", "jms:queue:b")
+	failed.topology = failed.topologyRoute()
+	failed.Endpoints.Inputs = append(failed.Endpoints.Inputs, "jms:queue:partial")
+	failed.UpdatingState = UPDATE_STATE_FAILED
+	addRoute("recorded", "Started", "jms:queue:a")
+	addRoute("unknown", "Started")
+	absent := addRoute("absent", NONE)
+	absent.topology = absent.topologyRoute()
+
+	routes := service.topologyRoutes()
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Route < routes[j].Route })
+	if len(routes) != 3 {
+		t.Fatalf("routes = %v, want failed, fetched and recorded", routes)
+	}
+	if routes[0].Route != "failed" || len(routes[0].Inputs) != 1 || routes[0].Inputs[0] != "jms:queue:b" {
+		t.Errorf("failed route = %+v, want the last fetched topology", routes[0])
+	}
+	if routes[1].Route != "fetched" || routes[1].Inputs[0] != "jms:queue:fetched" {
+		t.Errorf("fetched route = %+v", routes[1])
+	}
+	if routes[2] != recorded {
+		t.Errorf("not fetched route = %+v, want recorded topology", routes[2])
+	}
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const DefaultMaxIdleConnsPerService = 10
+
+// Creates client of service, it is reused for every request to keep connections alive
+func newHttpClient(config *TransportConfig, timeout time.Duration) (*http.Client, error) {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          DefaultMaxIdleConnsPerService,
+		MaxIdleConnsPerHost:   DefaultMaxIdleConnsPerService,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if config != nil {
+		tlsConfig, err := newTlsConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+		if config.Proxy != "" {
+			proxyUrl, err := url.Parse(config.Proxy)
+			if err != nil {
+				return nil, fmt.Errorf("proxy: %s", err)
+			}
+			transport.Proxy = http.ProxyURL(proxyUrl)
+		}
+		if config.MaxIdleConns > 0 {
+			transport.MaxIdleConns = config.MaxIdleConns
+			transport.MaxIdleConnsPerHost = config.MaxIdleConns
+		}
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+func newTlsConfig(config *TransportConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CaFile != "" {
+		pem, err := ioutil.ReadFile(config.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca file: %s", err)
+		}
+		// system roots are kept for other hosts like oauth2 token url
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca file %s has no certificates", config.CaFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return nil, errors.New("cert file and key file must be set together")
+	}
+	if config.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
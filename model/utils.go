@@ -1,28 +1,47 @@
 package model
 
 import (
+	"bytes"
+	"context"
 	"net/http"
 	"io/ioutil"
-	"time"
 	"errors"
 )
 
-func callEndpoint(url string, auth *Authorization) ([]byte, error) {
-	client := &http.Client{
-		Timeout: time.Duration(60 * time.Second),
-	}
+func callEndpoint(ctx context.Context, client *http.Client, url string, auth authenticator) ([]byte, error) {
 	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return doRequest(client, req.WithContext(ctx), auth)
+}
+
+func postEndpoint(ctx context.Context, client *http.Client, url string, body []byte, auth authenticator) ([]byte, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doRequest(client, req.WithContext(ctx), auth)
+}
+
+func doRequest(client *http.Client, req *http.Request, auth authenticator) ([]byte, error) {
 	if auth != nil {
-		req.SetBasicAuth(auth.Login, auth.Pass)
+		if err := auth.authenticate(req); err != nil {
+			return nil, err
+		}
 	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized && auth != nil {
+		auth.invalidate()
+	}
 	if resp.StatusCode != 200 {
 		return nil, errors.New("Status " + resp.Status)
 	}
-	defer resp.Body.Close()
 	//return json.NewDecoder(resp.Body).Decode(target)
 	return ioutil.ReadAll(resp.Body)
 }
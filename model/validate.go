@@ -0,0 +1,440 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Problem of config with json path of its place
+type ConfigProblem struct {
+	Path    string
+	Message string
+}
+
+func (problem *ConfigProblem) String() string {
+	return fmt.Sprintf("%s: %s", problem.Path, problem.Message)
+}
+
+type configProblems []*ConfigProblem
+
+func (problems *configProblems) add(path string, format string, args ...interface{}) {
+	*problems = append(*problems, &ConfigProblem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Problems as one error, nil if there are none
+func (problems configProblems) err() error {
+	if len(problems) == 0 {
+		return nil
+	}
+	messages := make([]string, len(problems))
+	for i, problem := range problems {
+		messages[i] = problem.String()
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// Reads config and returns it with every problem found in it, config is nil if it could not be decoded.
+// Error is returned only if file could not be read or parsed.
+func ValidateConfig(fileName string) (*InstanceConfig, []*ConfigProblem, error) {
+	problems := &configProblems{}
+	tree, err := readConfigTree(fileName, problems)
+	if err != nil {
+		return nil, nil, err
+	}
+	checkFields(tree, reflect.TypeOf(InstanceConfig{}), "$", problems)
+	config, err := decodeConfig(tree)
+	if err != nil {
+		problems.add("$", "%s", err)
+		return nil, *problems, nil
+	}
+	checkConfig(config, problems)
+	return config, *problems, nil
+}
+
+// Reports keys that do not match any field, matching is case insensitive like in encoding/json
+func checkFields(tree interface{}, t reflect.Type, path string, problems *configProblems) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := tree.(map[string]interface{})
+		if !ok {
+			return
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := object[key]
+			field, found := findField(t, key)
+			if !found {
+				problems.add(path+"."+key, "unknown field")
+				continue
+			}
+			checkFields(value, field.Type, path+"."+key, problems)
+		}
+	case reflect.Slice:
+		switch list := tree.(type) {
+		case []interface{}:
+			for i, value := range list {
+				checkFields(value, t.Elem(), fmt.Sprintf("%s[%v]", path, i), problems)
+			}
+		case []map[string]interface{}:
+			for i, value := range list {
+				checkFields(value, t.Elem(), fmt.Sprintf("%s[%v]", path, i), problems)
+			}
+		}
+	}
+}
+
+func findField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if embedded, found := findField(field.Type, key); found {
+				return embedded, true
+			}
+			continue
+		}
+		if field.PkgPath == "" && strings.EqualFold(field.Name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Paths of built-in handlers, metric sinks can not be exposed on them
+var reservedPaths = []string{"/", "/data", "/graph", "/diff", "/events", "/export/dot", "/export/mermaid",
+	"/history", "/alerts", "/topology/events", "/topology/snapshot"}
+
+// Path prefixes of built-in handlers
+var reservedPathPrefixes = []string{"/admin/", "/debug/pprof/"}
+
+func checkConfig(config *InstanceConfig, problems *configProblems) {
+	checkPolling(config.PollingConfig, "$", problems)
+	if len(config.Environments) == 0 {
+		problems.add("$.environments", "at least one environment is required")
+	}
+	environmentNames := make(map[string]string)
+	for i, environment := range config.Environments {
+		path := fmt.Sprintf("$.environments[%v]", i)
+		if environment.Name == "" {
+			problems.add(path+".name", "must not be empty")
+		} else if first, exists := environmentNames[environment.Name]; exists {
+			problems.add(path+".name", "environment %s is already defined at %s", environment.Name, first)
+		} else {
+			environmentNames[environment.Name] = path
+		}
+		checkPolling(environment.PollingConfig, path, problems)
+		serviceNames := make(map[string]string)
+		for j, service := range environment.Services {
+			servicePath := fmt.Sprintf("%s.services[%v]", path, j)
+			if service.Name == "" {
+				problems.add(servicePath+".name", "must not be empty")
+			} else if first, exists := serviceNames[service.Name]; exists {
+				problems.add(servicePath+".name", "service %s is already defined at %s", service.Name, first)
+			} else {
+				serviceNames[service.Name] = servicePath
+			}
+			checkPolling(service.PollingConfig, servicePath, problems)
+			checkServiceUrl(service.Url, servicePath+".url", problems)
+			if service.Color != "" && !colorPattern.MatchString(service.Color) {
+				problems.add(servicePath+".color", "%q is not a #rgb or #rrggbb color", service.Color)
+			}
+			if service.Authorization != nil {
+				checkAuthorization(service.Authorization, servicePath+".authorization", problems)
+			}
+			if service.Transport != nil {
+				checkTransport(service.Transport, servicePath+".transport", problems)
+			}
+		}
+	}
+	sinkPaths := make(map[string]string)
+	for i, sink := range config.Metrics {
+		path := fmt.Sprintf("$.metrics[%v]", i)
+		switch sink.Type {
+		case METRIC_SINK_GRAPHITE, METRIC_SINK_STATSD:
+			if sink.Url == "" {
+				problems.add(path+".url", "must not be empty for %s", sink.Type)
+			}
+		case METRIC_SINK_FILE:
+			if sink.File == "" {
+				problems.add(path+".file", "must not be empty for %s", sink.Type)
+			}
+		case METRIC_SINK_PROMETHEUS:
+			sinkPath := sink.Path
+			if sinkPath == "" {
+				sinkPath = DefaultPrometheusPath
+			}
+			if !strings.HasPrefix(sinkPath, "/") {
+				problems.add(path+".path", "must start with /")
+			} else if first, exists := sinkPaths[sinkPath]; exists {
+				problems.add(path+".path", "%s is already used by %s", sinkPath, first)
+			} else if isReservedPath(sinkPath) {
+				problems.add(path+".path", "%s is used by built-in handler", sinkPath)
+			} else {
+				sinkPaths[sinkPath] = path
+			}
+		default:
+			problems.add(path+".type", "unknown metric sink type %q", sink.Type)
+		}
+		if sink.BufferSize < 0 {
+			problems.add(path+".bufferSize", "must not be negative")
+		}
+	}
+	if config.History != nil && config.History.RetentionHours < 0 {
+		problems.add("$.history.retentionHours", "must not be negative")
+	}
+	if config.Topology != nil && config.Topology.RetentionDays < 0 {
+		problems.add("$.topology.retentionDays", "must not be negative")
+	}
+	if config.Alerts != nil {
+		checkAlerts(config.Alerts, problems)
+	}
+	if config.Notifications != nil {
+		checkNotifications(config.Notifications, problems)
+	}
+	if config.Access != nil {
+		checkAccess(config.Access, problems)
+	}
+	for i, rule := range config.EndpointRules {
+		if _, err := newEndpointRule(rule, nil); err != nil {
+			problems.add(fmt.Sprintf("$.endpointRules[%v]", i), "%s", err)
+		}
+	}
+}
+
+func isReservedPath(path string) bool {
+	for _, reserved := range reservedPaths {
+		if path == reserved {
+			return true
+		}
+	}
+	for _, prefix := range reservedPathPrefixes {
+		if strings.HasPrefix(path, prefix) || path+"/" == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+func checkPolling(config PollingConfig, path string, problems *configProblems) {
+	if config.ServiceUpdateIntervalSeconds < 0 {
+		problems.add(path+".serviceUpdateIntervalSeconds", "must not be negative")
+	}
+	if config.RouteUpdateIntervalSeconds < 0 {
+		problems.add(path+".routeUpdateIntervalSeconds", "must not be negative")
+	}
+	if config.TimeoutSeconds < 0 {
+		problems.add(path+".timeoutSeconds", "must not be negative")
+	}
+	if config.RouteWorkers < 0 {
+		problems.add(path+".routeWorkers", "must not be negative")
+	}
+	if config.RouteBatchSize < 0 {
+		problems.add(path+".routeBatchSize", "must not be negative")
+	}
+	if config.RouteGracePeriodSeconds < 0 {
+		problems.add(path+".routeGracePeriodSeconds", "must not be negative")
+	}
+	if config.Retry != nil {
+		if config.Retry.Attempts < 0 {
+			problems.add(path+".retry.attempts", "must not be negative")
+		}
+		if config.Retry.BackoffMillis < 0 {
+			problems.add(path+".retry.backoffMillis", "must not be negative")
+		}
+		if config.Retry.MaxBackoffMillis < 0 {
+			problems.add(path+".retry.maxBackoffMillis", "must not be negative")
+		}
+	}
+}
+
+func checkAuthorization(config *Authorization, path string, problems *configProblems) {
+	switch config.Type {
+	case "", AUTH_BASIC:
+		if config.Login == "" {
+			problems.add(path+".login", "must not be empty")
+		}
+	case AUTH_BEARER:
+		if config.Token == "" {
+			problems.add(path+".token", "must not be empty for bearer")
+		}
+	case AUTH_HEADERS:
+		if len(config.Headers) == 0 {
+			problems.add(path+".headers", "must not be empty for headers")
+		}
+	case AUTH_OAUTH2:
+		if config.TokenUrl == "" {
+			problems.add(path+".tokenUrl", "must not be empty for oauth2")
+		} else if _, err := url.ParseRequestURI(config.TokenUrl); err != nil {
+			problems.add(path+".tokenUrl", "%s", err)
+		}
+		if config.ClientId == "" {
+			problems.add(path+".clientId", "must not be empty for oauth2")
+		}
+	default:
+		problems.add(path+".type", "unknown authorization type %q", config.Type)
+	}
+}
+
+func checkTransport(config *TransportConfig, path string, problems *configProblems) {
+	files := []struct{ field, name string }{
+		{"caFile", config.CaFile}, {"certFile", config.CertFile}, {"keyFile", config.KeyFile}}
+	for _, file := range files {
+		if file.name == "" {
+			continue
+		}
+		if _, err := os.Stat(file.name); err != nil {
+			problems.add(path+"."+file.field, "%s", err)
+		}
+	}
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		problems.add(path, "certFile and keyFile must be set together")
+	}
+	if config.Proxy != "" {
+		if parsed, err := url.Parse(config.Proxy); err != nil {
+			problems.add(path+".proxy", "%s", err)
+		} else if parsed.Host == "" {
+			problems.add(path+".proxy", "host must not be empty")
+		}
+	}
+	if config.MaxIdleConns < 0 {
+		problems.add(path+".maxIdleConns", "must not be negative")
+	}
+}
+
+func checkAccess(config *AccessConfig, problems *configProblems) {
+	if config.UsersFile == "" && config.ProxyHeader == "" {
+		problems.add("$.access", "usersFile or proxyHeader is required")
+	}
+	roles := make(map[string]string)
+	for i, role := range config.Roles {
+		path := fmt.Sprintf("$.access.roles[%v]", i)
+		if role.Name == "" {
+			problems.add(path+".name", "must not be empty")
+		} else if first, exists := roles[role.Name]; exists {
+			problems.add(path+".name", "role %s is already defined at %s", role.Name, first)
+		} else {
+			roles[role.Name] = path
+		}
+	}
+	for i, role := range config.DefaultRoles {
+		if _, exists := roles[role]; !exists {
+			problems.add(fmt.Sprintf("$.access.defaultRoles[%v]", i), "unknown role %q", role)
+		}
+	}
+	if config.UsersFile == "" {
+		return
+	}
+	users, err := ReadUsers(config.UsersFile)
+	if err != nil {
+		problems.add("$.access.usersFile", "%s", err)
+		return
+	}
+	for _, user := range users.Users {
+		for _, role := range user.Roles {
+			if _, exists := roles[role]; !exists {
+				problems.add("$.access.usersFile", "unknown role %q of user %s", role, user.Login)
+			}
+		}
+	}
+}
+
+func checkServiceUrl(serviceUrl string, path string, problems *configProblems) {
+	if serviceUrl == "" {
+		problems.add(path, "must not be empty")
+		return
+	}
+	parsed, err := url.Parse(serviceUrl)
+	if err != nil {
+		problems.add(path, "%s", err)
+		return
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		problems.add(path, "scheme must be http or https")
+	}
+	if parsed.Host == "" {
+		problems.add(path, "host must not be empty")
+	}
+}
+
+func checkAlerts(config *AlertsConfig, problems *configProblems) {
+	if config.EvaluationIntervalSeconds < 0 {
+		problems.add("$.alerts.evaluationIntervalSeconds", "must not be negative")
+	}
+	names := make(map[string]string)
+	for i, rule := range config.Rules {
+		path := fmt.Sprintf("$.alerts.rules[%v]", i)
+		if rule.Name == "" {
+			problems.add(path+".name", "must not be empty")
+		} else if first, exists := names[rule.Name]; exists {
+			problems.add(path+".name", "rule %s is already defined at %s", rule.Name, first)
+		} else {
+			names[rule.Name] = path
+		}
+		switch rule.Type {
+		case ALERT_ROUTE_STATE, ALERT_ROUTE_FAILURE_RATE, ALERT_ROUTE_INFLIGHT, ALERT_SERVICE_FAILED:
+		default:
+			problems.add(path+".type", "unknown alert rule type %q", rule.Type)
+		}
+		if rule.Threshold < 0 {
+			problems.add(path+".threshold", "must not be negative")
+		}
+		if rule.Updates < 0 {
+			problems.add(path+".updates", "must not be negative")
+		}
+		if rule.ForSeconds < 0 {
+			problems.add(path+".forSeconds", "must not be negative")
+		}
+	}
+}
+
+func checkNotifications(config *NotificationsConfig, problems *configProblems) {
+	for i, channel := range config.Channels {
+		path := fmt.Sprintf("$.notifications.channels[%v]", i)
+		if channel.Name == "" {
+			problems.add(path+".name", "must not be empty")
+		}
+		switch channel.Type {
+		case NOTIFICATION_CHANNEL_WEBHOOK, NOTIFICATION_CHANNEL_SLACK:
+			if channel.Url == "" {
+				problems.add(path+".url", "must not be empty for %s", channel.Type)
+			} else if _, err := url.ParseRequestURI(channel.Url); err != nil {
+				problems.add(path+".url", "%s", err)
+			}
+			if channel.Template != "" {
+				if _, err := template.New("webhook").Funcs(webhookTemplateFuncs).Parse(channel.Template); err != nil {
+					problems.add(path+".template", "%s", err)
+				}
+			}
+		case NOTIFICATION_CHANNEL_EMAIL:
+			if channel.Smtp == nil || channel.Smtp.Host == "" {
+				problems.add(path+".smtp.host", "must not be empty for email")
+			}
+			if channel.Smtp != nil && channel.Smtp.From == "" {
+				problems.add(path+".smtp.from", "must not be empty for email")
+			}
+			if len(channel.To) == 0 {
+				problems.add(path+".to", "at least one recipient is required")
+			}
+		default:
+			problems.add(path+".type", "unknown notification channel type %q", channel.Type)
+		}
+		if channel.MaxPerMinute < 0 {
+			problems.add(path+".maxPerMinute", "must not be negative")
+		}
+	}
+}
@@ -56,9 +56,24 @@ function placeController($scope, data, $timeout, $http, $stateParams, $location,
     $scope.data = $scope.merge({}, data)
     utils.processSchema($scope.data)
     $scope.graph = utils.buildGraphFromSchema($scope.data)
+    // the last full environment with applied live updates
+    $scope.rawData = data
+
+    $scope.render = function (newData) {
+        $scope.rawData = newData
+        var data = $scope.merge({}, newData)
+        utils.processSchema(data)
+        utils.updateGraph($scope.graph, data)
+    }
 
     $scope.listen = function (delay) {
         $timeout(function () {
+            // live updates make polling unnecessary
+            if ($scope.streaming) {
+                if (!!$scope.isDestroed) return
+                $scope.listen($scope.dataUpdateIntervalSeconds * 1000)
+                return
+            }
             $http({
                 method: 'GET',
                 url: '/data?env=' + $stateParams.component + "&t=" + new Date().getTime(),
@@ -67,9 +82,7 @@ function placeController($scope, data, $timeout, $http, $stateParams, $location,
                 .success(function (newData) {
                     console.info("Has come new data: " + newData)
                     $scope.connection.error = null
-                    var data = $scope.merge({}, newData)
-                    utils.processSchema(data)
-                    utils.updateGraph($scope.graph, data)
+                    $scope.render(newData)
                 })
                 .error(function (error, error2, error3) {
                     $scope.connection.error = "Connection with server is lost"
@@ -80,6 +93,85 @@ function placeController($scope, data, $timeout, $http, $stateParams, $location,
     }
     $scope.listen($scope.dataUpdateIntervalSeconds * 1000)
 
+    // Applies update of /events stream to the last environment, graph is redrawn once for a burst of updates
+    $scope.applyUpdate = function (update) {
+        // updates that come while environment is reread are applied on top of it
+        if ($scope.pendingUpdates) {
+            $scope.pendingUpdates.push(update)
+            return
+        }
+        var service = $scope.rawData.serviceMap && $scope.rawData.serviceMap[update.service]
+        if (!service) return
+        service.routeMap = service.routeMap || {}
+        if (update.type == 'service_updated') {
+            service.error = update.state.error
+            service.color = update.state.color
+            service.lastUpdated = update.state.lastUpdated
+            service.updatingState = update.state.updatingState
+            service.failedUpdates = update.state.failedUpdates
+        }
+        for (var key in update.routes) {
+            if (update.routes.hasOwnProperty(key)) {
+                var route = update.routes[key]
+                var existed = service.routeMap[key]
+                if (existed && !route.schema) {
+                    route.schema = existed.schema
+                }
+                service.routeMap[key] = route
+            }
+        }
+        for (var i = 0; update.keys && i < update.keys.length; i++) {
+            delete service.routeMap[update.keys[i]]
+        }
+        if (!$scope.renderPending) {
+            $scope.renderPending = $timeout(function () {
+                $scope.renderPending = null
+                $scope.render($scope.rawData)
+            }, 1000)
+        }
+    }
+    if (window.EventSource) {
+        var source = new EventSource('/events?env=' + $stateParams.component)
+        var onUpdate = function (event) {
+            $scope.$apply(function () {
+                $scope.applyUpdate(JSON.parse(event.data))
+            })
+        }
+        var types = ['routes_added', 'routes_updated', 'routes_removed', 'routes_evicted', 'service_updated']
+        for (var i = 0; i < types.length; i++) {
+            source.addEventListener(types[i], onUpdate)
+        }
+        source.onopen = function () {
+            $scope.$apply(function () {
+                $scope.connection.error = null
+                // stream has no initial state, updates could be missed before it is opened or while it was closed
+                $scope.pendingUpdates = []
+                var done = function () {
+                    var updates = $scope.pendingUpdates || []
+                    $scope.pendingUpdates = null
+                    for (var i = 0; i < updates.length; i++) {
+                        $scope.applyUpdate(updates[i])
+                    }
+                }
+                $http.get('/data?env=' + $stateParams.component + "&t=" + new Date().getTime())
+                    .success(function (newData) {
+                        $scope.render(newData)
+                        done()
+                    })
+                    .error(done)
+                $scope.streaming = true
+            })
+        }
+        source.onerror = function () {
+            $scope.$apply(function () {
+                $scope.streaming = false
+            })
+        }
+        $scope.$on('$destroy', function () {
+            source.close()
+        });
+    }
+
     $scope.drawGraph = function (nodes, edges) {
         console.info("Start draw")
         var data = {
@@ -57,6 +57,7 @@ angular.module('flow').service('utils', function () {
                         + '<br/>State: ' + (route.state || 'none')
                         + '<br/>Uptime: ' + (route.uptime || '-')
                         + '<br/>LastUpdated: ' + (route.lastUpdated ? moment(route.lastUpdated).fromNow(): "-")
+                        + (route.removedAt ? '<br/>Removed: ' + moment(route.removedAt).fromNow() : '')
                         + '<br/> ----'
                         + '<br/>exchangesTotal: ' + (route.exchangesTotal || 0)
                         + '<br/>exchangesCompleted: ' + (route.exchangesCompleted || 0)
@@ -70,6 +71,9 @@ angular.module('flow').service('utils', function () {
                         + '<br/>failuresHandled: ' + (route.failuresHandled || 0)
                         + '<br/>redeliveries: ' + (route.redeliveries || 0)
                         + '<br/>startTimestamp: ' + (route.startTimestamp || '-')
+                        + '<br/> ----'
+                        + '<br/>exchangesPerSecond: ' + (route.exchangesPerSecond || 0).toFixed(2)
+                        + '<br/>failureRatio: ' + (route.failureRatio || 0).toFixed(2)
                     return title
                 },
                 addEdge: function (route, from, to, service) {